@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/adikari/safebox/v2/aws"
+	"github.com/adikari/safebox/v2/util"
+)
+
+const (
+	ActionDeploy       = "deploy"
+	ActionRemoveOrphan = "remove-orphan"
+	ActionRestore      = "restore"
+	ActionSet          = "set"
+	ActionDelete       = "delete"
+)
+
+// Event is a single change made through safebox. Values are never recorded,
+// only their hash.
+type Event struct {
+	Time      time.Time `json:"time" yaml:"time"`
+	Identity  string    `json:"identity" yaml:"identity"`
+	Action    string    `json:"action" yaml:"action"`
+	Service   string    `json:"service" yaml:"service"`
+	Stage     string    `json:"stage" yaml:"stage"`
+	Name      string    `json:"name" yaml:"name"`
+	Key       string    `json:"key" yaml:"key"`
+	Version   string    `json:"version,omitempty" yaml:"version,omitempty"`
+	ValueHash string    `json:"valueHash,omitempty" yaml:"valueHash,omitempty"`
+}
+
+// Sink is where audit events are written
+type Sink interface {
+	Write(ctx context.Context, events []Event) error
+}
+
+// Reader is a sink whose events can be queried
+type Reader interface {
+	Read(ctx context.Context) ([]Event, error)
+}
+
+// NewSink returns the sink for a jsonl file path, s3://bucket/prefix or
+// http(s) webhook url
+func NewSink(sink string, session aws.SessionConfig) (Sink, error) {
+	u, err := url.Parse(sink)
+
+	if err != nil || u.Scheme == "" || u.Scheme == "file" {
+		path := strings.TrimPrefix(sink, "file://")
+		return &FileSink{Path: path}, nil
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return &S3Sink{
+			Bucket:  u.Host,
+			Prefix:  strings.Trim(u.Path, "/"),
+			Session: session,
+		}, nil
+	case "http", "https":
+		return &WebhookSink{Url: sink, Token: os.Getenv("SAFEBOX_AUDIT_TOKEN")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink `%s`", sink)
+	}
+}
+
+// HashValue returns the hmac-sha256 of a value. A plain hash of a short
+// secret can be brute forced from the log, so nothing is returned without
+// a key.
+func HashValue(key string, value string) string {
+	if key == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(value))
+
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Identity returns the arn of the aws caller, or the local user for other
+// providers
+func Identity(ctx context.Context, provider string, session aws.SessionConfig) string {
+	if util.IsAwsProvider(provider) {
+		if ses, err := aws.NewSession(ctx, session); err == nil {
+			st := aws.NewSts(ses)
+
+			if id, err := st.GetCallerIdentity(ctx); err == nil {
+				return *id.Arn
+			}
+		}
+	}
+
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		name = fmt.Sprintf("%s@%s", name, host)
+	}
+
+	return name
+}
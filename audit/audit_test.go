@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHashValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string
+	}{
+		{name: "rfc 4231 case 2", key: "Jefe", value: "what do ya want for nothing?", want: "hmac-sha256:5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{name: "empty value", key: "Jefe", value: "", want: "hmac-sha256:923598ca6d64af2a5dba79dcd021a8a0fe5c5f557519adaaf0ad532d4506dd30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashValue(tt.key, tt.value); got != tt.want {
+				t.Errorf("HashValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if HashValue("a", "secret") == HashValue("b", "secret") {
+		t.Error("hashes of different keys must differ")
+	}
+}
+
+func TestHashValueWithoutKey(t *testing.T) {
+	for _, value := range []string{"secret", ""} {
+		if got := HashValue("", value); got != "" {
+			t.Errorf("HashValue(%q) = %q, want no hash without a key", value, got)
+		}
+	}
+
+	b, err := json.Marshal(Event{Name: "/dev/api/API_KEY", ValueHash: HashValue("", "secret")})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "valueHash") {
+		t.Errorf("event = %s, want the hash omitted", b)
+	}
+}
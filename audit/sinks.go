@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adikari/safebox/v2/aws"
+	"github.com/pkg/errors"
+)
+
+// FileSink appends events to a local jsonl file
+type FileSink struct {
+	Path string
+}
+
+func (s *FileSink) Write(_ context.Context, events []Event) error {
+	if dir := filepath.Dir(s.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	b, err := encode(events)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(b)
+	return err
+}
+
+func (s *FileSink) Read(_ context.Context) ([]Event, error) {
+	b, err := ioutil.ReadFile(s.Path)
+
+	if os.IsNotExist(err) {
+		return []Event{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(b)
+}
+
+// S3Sink writes each batch of events as a jsonl object under the prefix
+type S3Sink struct {
+	Bucket  string
+	Prefix  string
+	Session aws.SessionConfig
+}
+
+func (s *S3Sink) client(ctx context.Context) (aws.S3, error) {
+	ses, err := aws.NewSession(ctx, s.Session)
+
+	if err != nil {
+		return aws.S3{}, err
+	}
+
+	return aws.NewS3(ses), nil
+}
+
+func (s *S3Sink) Write(ctx context.Context, events []Event) error {
+	b, err := encode(events)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s/%d.jsonl", now.Format("2006/01/02"), now.UnixNano())
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+
+	c, err := s.client(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return c.PutObject(ctx, s.Bucket, key, b)
+}
+
+func (s *S3Sink) Read(ctx context.Context) ([]Event, error) {
+	c, err := s.client(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := s.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	keys, err := c.ListKeys(ctx, s.Bucket, prefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	events := []Event{}
+
+	for _, key := range keys {
+		b, err := c.GetObject(ctx, s.Bucket, key)
+
+		if err != nil {
+			return nil, errors.Wrap(err, key)
+		}
+
+		e, err := decode(b)
+
+		if err != nil {
+			return nil, errors.Wrap(err, key)
+		}
+
+		events = append(events, e...)
+	}
+
+	return events, nil
+}
+
+// WebhookSink posts events as a json array. SAFEBOX_AUDIT_TOKEN is sent as
+// a bearer token when set.
+type WebhookSink struct {
+	Url   string
+	Token string
+}
+
+func (s *WebhookSink) Write(ctx context.Context, events []Event) error {
+	b, err := json.Marshal(events)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Url, bytes.NewReader(b))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+func encode(events []Event) ([]byte, error) {
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+
+	for _, event := range events {
+		if err := e.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+func decode(b []byte) ([]Event, error) {
+	events := []Event{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, scanner.Err()
+}
@@ -1,35 +1,31 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 )
 
-var cfClient *cloudformation.CloudFormation
-
 type Cloudformation struct {
-	client *cloudformation.CloudFormation
+	client *cloudformation.Client
 }
 
-func NewCloudformation(session *session.Session) Cloudformation {
-	if cfClient == nil {
-		cfClient = cloudformation.New(session)
-	}
-
-	return Cloudformation{client: cfClient}
+func NewCloudformation(session Session) Cloudformation {
+	return Cloudformation{client: cloudformation.NewFromConfig(session.Config, func(o *cloudformation.Options) {
+		o.BaseEndpoint = session.Endpoint("cloudformation")
+	})}
 }
 
-func (c *Cloudformation) GetOutput(stackname string) (map[string]string, error) {
+func (c *Cloudformation) GetOutput(ctx context.Context, stackname string) (map[string]string, error) {
 	result := map[string]string{}
 
-	resp, _ := c.client.DescribeStacks(&cloudformation.DescribeStacksInput{
+	resp, err := c.client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
 		StackName: aws.String(stackname),
 	})
 
-	if len(resp.Stacks) <= 0 {
+	if err != nil || len(resp.Stacks) <= 0 {
 		return nil, fmt.Errorf("%s stack does not exist", stackname)
 	}
 
@@ -42,11 +38,11 @@ func (c *Cloudformation) GetOutput(stackname string) (map[string]string, error)
 	return result, nil
 }
 
-func (c *Cloudformation) GetOutputs(stacknames []string) (map[string]string, error) {
+func (c *Cloudformation) GetOutputs(ctx context.Context, stacknames []string) (map[string]string, error) {
 	result := map[string]string{}
 
 	for _, stackname := range stacknames {
-		outputs, err := c.GetOutput(stackname)
+		outputs, err := c.GetOutput(ctx, stackname)
 
 		if err != nil {
 			continue
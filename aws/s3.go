@@ -0,0 +1,71 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type S3 struct {
+	client *s3.Client
+}
+
+func NewS3(session Session) S3 {
+	return S3{client: s3.NewFromConfig(session.Config, func(o *s3.Options) {
+		o.BaseEndpoint = session.Endpoint("s3")
+		// localstack and moto serve buckets by path
+		o.UsePathStyle = o.BaseEndpoint != nil
+	})}
+}
+
+func (s *S3) PutObject(ctx context.Context, bucket string, key string, body []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	})
+
+	return err
+}
+
+func (s *S3) GetObject(ctx context.Context, bucket string, key string) ([]byte, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// ListKeys returns keys of all objects under the prefix
+func (s *S3) ListKeys(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	keys := []string{}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range page.Contents {
+			keys = append(keys, *o.Key)
+		}
+	}
+
+	return keys, nil
+}
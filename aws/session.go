@@ -1,33 +1,141 @@
 package aws
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/client"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-var ses *session.Session
+// SessionConfig selects the region, credentials and endpoints of a session
+type SessionConfig struct {
+	Region     string
+	Profile    string
+	RoleArn    string
+	ExternalId string
+	MfaSerial  string
+	// EndpointUrl overrides the endpoint of all services, eg. localstack
+	EndpointUrl string
+	// Endpoints overrides the endpoint of a service by its id, eg. ssm
+	Endpoints map[string]string
+}
+
+// Session is the aws config shared by all clients of a store
+type Session struct {
+	Config    aws.Config
+	endpoints SessionConfig
+}
+
+type credentialsKey struct {
+	profile    string
+	roleArn    string
+	externalId string
+	mfaSerial  string
+	// endpointUrl of sts, roles of localstack are not the roles of aws
+	endpointUrl string
+}
+
+// credentialsCache shares credentials between sessions of different regions
+// so a role is assumed, and mfa token asked, once per run. Stores of several
+// stages create sessions concurrently.
+var (
+	credentialsMu    sync.Mutex
+	credentialsCache = map[credentialsKey]aws.CredentialsProvider{}
+)
+
+// NewSession loads aws config for the given region, profile and role. Region
+// falls back to AWS_REGION and the profile. Credentials are resolved by the
+// default chain, which includes sso, web identity and imds.
+func NewSession(ctx context.Context, cfg SessionConfig) (Session, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRetryMaxAttempts(3),
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = mfaToken
+		}),
+	}
+
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+
+	if cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, opts...)
+
+	if err != nil {
+		return Session{}, err
+	}
+
+	s := Session{Config: awsConfig, endpoints: cfg}
 
-func NewSession(cfg aws.Config) *session.Session {
-	if ses == nil {
-		if cfg.Retryer == nil {
-			cfg.Retryer = Retryer
-		}
+	key := credentialsKey{cfg.Profile, cfg.RoleArn, cfg.ExternalId, cfg.MfaSerial, aws.ToString(s.Endpoint("sts"))}
 
-		if cfg.Region == nil {
-			region := os.Getenv("AWS_REGION")
-			cfg.Region = &region
-		}
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
 
-		ses = session.Must(session.NewSession(&cfg))
+	if creds, ok := credentialsCache[key]; ok {
+		s.Config.Credentials = creds
+		return s, nil
 	}
 
-	return ses
+	if cfg.RoleArn != "" {
+		client := sts.NewFromConfig(awsConfig, func(o *sts.Options) {
+			o.BaseEndpoint = s.Endpoint("sts")
+		})
+
+		s.Config.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, cfg.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "safebox"
+
+			if cfg.ExternalId != "" {
+				o.ExternalID = aws.String(cfg.ExternalId)
+			}
+
+			if cfg.MfaSerial != "" {
+				o.SerialNumber = aws.String(cfg.MfaSerial)
+				o.TokenProvider = mfaToken
+			}
+		}))
+	}
+
+	credentialsCache[key] = s.Config.Credentials
+
+	return s, nil
+}
+
+// Region of the session, resolved from config, environment or profile
+func (s Session) Region() string {
+	return s.Config.Region
 }
 
-var Retryer = client.DefaultRetryer{
-	NumMaxRetries:    2,
-	MinThrottleDelay: client.DefaultRetryerMaxRetryDelay,
+// Endpoint returns the endpoint override of a service, or nil to use the
+// default endpoint. AWS_ENDPOINT_URL_<SERVICE> is also honoured by the sdk.
+func (s Session) Endpoint(service string) *string {
+	if url, ok := s.endpoints.Endpoints[service]; ok && url != "" {
+		return aws.String(url)
+	}
+
+	if s.endpoints.EndpointUrl != "" {
+		return aws.String(s.endpoints.EndpointUrl)
+	}
+
+	return nil
+}
+
+// mfaToken reads the mfa token code from stdin. The prompt is written to
+// stderr to keep stdout clean for exports.
+func mfaToken() (string, error) {
+	fmt.Fprint(os.Stderr, "MFA token code: ")
+
+	var token string
+	_, err := fmt.Scanln(&token)
+
+	return strings.TrimSpace(token), err
 }
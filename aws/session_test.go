@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// isolate keeps sessions from reading the aws config of the user
+func isolate(t *testing.T, config string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", path)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+}
+
+func TestNewSessionRegion(t *testing.T) {
+	isolate(t, "[profile prod]\nregion = eu-west-1\n")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		cfg  SessionConfig
+		want string
+	}{
+		{"region", SessionConfig{Region: "us-east-1"}, "us-east-1"},
+		{"another region", SessionConfig{Region: "ap-southeast-2"}, "ap-southeast-2"},
+		{"region of profile", SessionConfig{Profile: "prod"}, "eu-west-1"},
+		{"region overrides profile", SessionConfig{Profile: "prod", Region: "us-west-2"}, "us-west-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSession(ctx, tt.cfg)
+
+			if err != nil {
+				t.Fatalf("NewSession() error = %v", err)
+			}
+
+			if s.Region() != tt.want {
+				t.Errorf("Region() = %s, want %s", s.Region(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSessionSharesCredentials(t *testing.T) {
+	isolate(t, "")
+	ctx := context.Background()
+
+	session := func(cfg SessionConfig) Session {
+		s, err := NewSession(ctx, cfg)
+		if err != nil {
+			t.Fatalf("NewSession() error = %v", err)
+		}
+		return s
+	}
+
+	role := "arn:aws:iam::123456789012:role/deploy"
+
+	dev := session(SessionConfig{Region: "us-east-1", RoleArn: role})
+	prod := session(SessionConfig{Region: "eu-west-1", RoleArn: role})
+
+	if dev.Config.Credentials != prod.Config.Credentials {
+		t.Errorf("sessions of the same role do not share credentials")
+	}
+
+	other := session(SessionConfig{Region: "us-east-1", RoleArn: role, ExternalId: "other"})
+
+	if other.Config.Credentials == dev.Config.Credentials {
+		t.Errorf("sessions with another external id share credentials")
+	}
+
+	local := session(SessionConfig{Region: "us-east-1", RoleArn: role, EndpointUrl: "http://localhost:4566"})
+
+	if local.Config.Credentials == dev.Config.Credentials {
+		t.Errorf("sessions with another sts endpoint share credentials")
+	}
+}
+
+func TestSessionEndpoint(t *testing.T) {
+	s := Session{endpoints: SessionConfig{
+		EndpointUrl: "http://localhost:4566",
+		Endpoints:   map[string]string{"ssm": "http://localhost:4567", "sts": ""},
+	}}
+
+	tests := []struct {
+		service string
+		want    string
+	}{
+		{"ssm", "http://localhost:4567"},
+		{"sts", "http://localhost:4566"},
+		{"secretsmanager", "http://localhost:4566"},
+	}
+
+	for _, tt := range tests {
+		if got := aws.ToString(s.Endpoint(tt.service)); got != tt.want {
+			t.Errorf("Endpoint(%s) = %s, want %s", tt.service, got, tt.want)
+		}
+	}
+
+	if got := (Session{}).Endpoint("ssm"); got != nil {
+		t.Errorf("Endpoint(ssm) = %s, want the default endpoint", *got)
+	}
+}
@@ -1,18 +1,21 @@
 package aws
 
 import (
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/sts"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 type Sts struct {
-	client *sts.STS
+	client *sts.Client
 }
 
-func NewSts(session *session.Session) Sts {
-	return Sts{client: sts.New(session)}
+func NewSts(session Session) Sts {
+	return Sts{client: sts.NewFromConfig(session.Config, func(o *sts.Options) {
+		o.BaseEndpoint = session.Endpoint("sts")
+	})}
 }
 
-func (s *Sts) GetCallerIdentity() (*sts.GetCallerIdentityOutput, error) {
-	return s.client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+func (s *Sts) GetCallerIdentity(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+	return s.client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 }
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+)
+
+const archiveVersion = 1
+
+// Archive is the content of a backup, before encryption
+type Archive struct {
+	Version      int            `json:"version" yaml:"version"`
+	Created      time.Time      `json:"created" yaml:"created"`
+	Service      string         `json:"service" yaml:"service"`
+	Stage        string         `json:"stage" yaml:"stage"`
+	Provider     string         `json:"provider" yaml:"provider"`
+	Region       string         `json:"region,omitempty" yaml:"region,omitempty"`
+	Prefix       string         `json:"prefix" yaml:"prefix"`
+	SharedPrefix string         `json:"sharedPrefix" yaml:"sharedPrefix"`
+	Params       []ArchiveParam `json:"params" yaml:"params"`
+}
+
+type ArchiveParam struct {
+	Name        string            `json:"name" yaml:"name"`
+	Value       string            `json:"value" yaml:"value"`
+	Type        string            `json:"type" yaml:"type"`
+	DataType    string            `json:"dataType,omitempty" yaml:"dataType,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string            `json:"version,omitempty" yaml:"version,omitempty"`
+	Modified    time.Time         `json:"modified" yaml:"modified"`
+	Secret      bool              `json:"secret" yaml:"secret"`
+	Shared      bool              `json:"shared" yaml:"shared"`
+	Orphan      bool              `json:"orphan,omitempty" yaml:"orphan,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// encryptArchive encrypts data to age recipients, gpg recipients or a
+// passphrase. Recipients starting with age1 are age keys, others are gpg
+// key ids or emails.
+func encryptArchive(data []byte, recipients []string, passphrase bool) ([]byte, error) {
+	var ageRecipients []age.Recipient
+	var gpgRecipients []string
+
+	for _, r := range recipients {
+		if strings.HasPrefix(r, "age1") {
+			parsed, err := age.ParseX25519Recipient(r)
+			if err != nil {
+				return nil, errors.Wrap(err, r)
+			}
+			ageRecipients = append(ageRecipients, parsed)
+		} else {
+			gpgRecipients = append(gpgRecipients, r)
+		}
+	}
+
+	switch {
+	case passphrase && len(recipients) > 0:
+		return nil, errors.New("use either recipients or a passphrase")
+	case len(ageRecipients) > 0 && len(gpgRecipients) > 0:
+		return nil, errors.New("age and gpg recipients can not be mixed")
+	case len(gpgRecipients) > 0:
+		return encryptGpg(data, gpgRecipients)
+	case passphrase:
+		p, err := readPassphrase(true)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := age.NewScryptRecipient(p)
+		if err != nil {
+			return nil, err
+		}
+		ageRecipients = append(ageRecipients, r)
+	case len(ageRecipients) == 0:
+		return nil, errors.New("backup must be encrypted. use --recipient or --passphrase")
+	}
+
+	var b bytes.Buffer
+	a := armor.NewWriter(&b)
+
+	w, err := age.Encrypt(a, ageRecipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := a.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// decryptArchive decrypts an age or gpg encrypted archive. Age archives are
+// decrypted with the identity files, or a passphrase when none are given.
+func decryptArchive(data []byte, identityFiles []string) ([]byte, error) {
+	if !isAgeArchive(data) {
+		return decryptGpg(data)
+	}
+
+	var identities []age.Identity
+
+	for _, path := range identityFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		ids, err := age.ParseIdentities(f)
+		f.Close()
+
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+		identities = append(identities, ids...)
+	}
+
+	if len(identities) == 0 {
+		p, err := readPassphrase(false)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := age.NewScryptIdentity(p)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, id)
+	}
+
+	var in io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte(armor.Header)) {
+		in = armor.NewReader(in)
+	}
+
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func isAgeArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(armor.Header)) || bytes.HasPrefix(data, []byte("age-encryption.org/"))
+}
+
+func encryptGpg(data []byte, recipients []string) ([]byte, error) {
+	args := []string{"--quiet", "--batch", "--armor", "--encrypt", "--trust-model", "always"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+
+	return runGpg(data, args...)
+}
+
+func decryptGpg(data []byte) ([]byte, error) {
+	return runGpg(data, "--quiet", "--batch", "--decrypt")
+}
+
+func runGpg(data []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("gpg", args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = os.Stderr
+
+	b, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "gpg failed")
+	}
+
+	return b, nil
+}
+
+// readPassphrase reads SAFEBOX_BACKUP_PASSPHRASE or asks for it. New
+// passphrases are asked twice.
+func readPassphrase(confirmation bool) (string, error) {
+	if p := os.Getenv("SAFEBOX_BACKUP_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	p := promptui.Prompt{Label: "Passphrase", Mask: '*', Stdout: os.Stderr}
+
+	passphrase, err := p.Run()
+	if err != nil {
+		return "", errors.Wrap(err, "aborted")
+	}
+
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+
+	if confirmation {
+		again := promptui.Prompt{Label: "Confirm passphrase", Mask: '*', Stdout: os.Stderr}
+
+		confirmed, err := again.Run()
+		if err != nil {
+			return "", errors.Wrap(err, "aborted")
+		}
+
+		if confirmed != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
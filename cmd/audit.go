@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adikari/safebox/v2/audit"
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditKey     string
+	auditAction  string
+	auditSince   time.Duration
+	auditLimit   int
+	auditAllRuns bool
+
+	auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Shows audit events of changes made through safebox",
+		Long: `Shows audit events of changes made through safebox, newest first.
+
+Events are written to the sink configured with audit.sink in the config file.
+Webhook sinks can not be queried.`,
+		RunE: auditE,
+	}
+)
+
+func init() {
+	auditCmd.Flags().StringVarP(&auditKey, "key", "k", "", "only show events of the key")
+	auditCmd.Flags().StringVarP(&auditAction, "action", "a", "", "only show events of the action (deploy, remove-orphan)")
+	auditCmd.Flags().DurationVar(&auditSince, "since", 0, "only show events newer than the duration, eg. 24h")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 50, "most events to show, 0 for all")
+	auditCmd.Flags().BoolVar(&auditAllRuns, "all", false, "show events of all services and stages")
+
+	rootCmd.AddCommand(auditCmd)
+}
+
+func auditE(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	if config.Audit.Sink == "" {
+		return errors.New("audit.sink is not configured")
+	}
+
+	sink, err := audit.NewSink(config.Audit.Sink, config.Session)
+
+	if err != nil {
+		return err
+	}
+
+	reader, ok := sink.(audit.Reader)
+
+	if !ok {
+		return fmt.Errorf("audit sink `%s` can not be queried", config.Audit.Sink)
+	}
+
+	all, err := reader.Read(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read audit events")
+	}
+
+	events := []audit.Event{}
+
+	for _, e := range all {
+		if !auditAllRuns && (e.Service != config.Service || e.Stage != config.Stage) {
+			continue
+		}
+		if auditKey != "" && e.Key != auditKey {
+			continue
+		}
+		if auditAction != "" && e.Action != auditAction {
+			continue
+		}
+		if auditSince > 0 && e.Time.Before(time.Now().Add(-auditSince)) {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+
+	if auditLimit > 0 && len(events) > auditLimit {
+		events = events[:auditLimit]
+	}
+
+	return printResult("audit", config, events, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+
+		fmt.Fprintln(w, "Time\tAction\tName\tVersion\tIdentity\tValueHash")
+
+		for _, e := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				e.Time.Local().Format(TimeFormat),
+				e.Action,
+				e.Name,
+				e.Version,
+				e.Identity,
+				e.ValueHash,
+			)
+		}
+		fmt.Fprintln(w, "---")
+		w.Flush()
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("Total events = %d", len(events)),
+			Config:  *config,
+		})
+	})
+}
+
+// recordAudit writes an event for each changed parameter to the configured
+// sink. Failures are reported but do not fail the command as the change has
+// already been made.
+func recordAudit(ctx context.Context, st store.Store, config *c.Config, action string, inputs []store.ConfigInput) {
+	if config.Audit.Sink == "" || len(inputs) == 0 {
+		return
+	}
+
+	if err := writeAudit(ctx, st, config, action, inputs); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit events: %s\n", err)
+	}
+}
+
+func writeAudit(ctx context.Context, st store.Store, config *c.Config, action string, inputs []store.ConfigInput) error {
+	sink, err := audit.NewSink(config.Audit.Sink, config.Session)
+
+	if err != nil {
+		return err
+	}
+
+	versions := map[string]string{}
+	removed := action == audit.ActionRemoveOrphan || action == audit.ActionDelete
+
+	if !removed {
+		current, err := st.GetMany(ctx, inputs)
+
+		if err != nil {
+			return errors.Wrap(err, "failed to read versions")
+		}
+
+		for _, p := range current {
+			versions[*p.Name] = p.Version
+		}
+	}
+
+	now := time.Now().UTC()
+	identity := audit.Identity(ctx, config.Provider, config.Session)
+	events := []audit.Event{}
+
+	for _, in := range inputs {
+		e := audit.Event{
+			Time:     now,
+			Identity: identity,
+			Action:   action,
+			Service:  config.Service,
+			Stage:    config.Stage,
+			Name:     in.Name,
+			Key:      in.Key(),
+			Version:  versions[in.Name],
+		}
+
+		if !removed {
+			e.ValueHash = audit.HashValue(config.Audit.HashKey, in.Value)
+		}
+
+		events = append(events, e)
+	}
+
+	return sink.Write(ctx, events)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupFile       string
+	backupRecipients []string
+	backupPassphrase bool
+
+	backupCmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Backs up all parameters of a stage to an encrypted archive",
+		Long: `Backs up declared, shared and orphan parameters of a stage with their values,
+types, descriptions, versions and tags to a single encrypted archive.
+
+The archive is encrypted to age recipients (age1...), gpg recipients (key id
+or email) or a passphrase. The passphrase is read from SAFEBOX_BACKUP_PASSPHRASE
+when set.`,
+		RunE: backup,
+	}
+)
+
+type BackupDoc struct {
+	File   string `json:"file" yaml:"file"`
+	Params int    `json:"params" yaml:"params"`
+}
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupFile, "output-file", "o", "", "path of the archive")
+	backupCmd.Flags().StringSliceVarP(&backupRecipients, "recipient", "r", []string{}, "age public key or gpg key id to encrypt to")
+	backupCmd.Flags().BoolVar(&backupPassphrase, "passphrase", false, "encrypt with a passphrase")
+	backupCmd.MarkFlagRequired("output-file")
+	backupCmd.MarkFlagFilename("output-file")
+
+	rootCmd.AddCommand(backupCmd)
+}
+
+func backup(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate store")
+	}
+
+	archive, err := snapshot(ctx, st, config)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(archive)
+
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptArchive(data, backupRecipients, backupPassphrase)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt backup")
+	}
+
+	if err := writeOutputFile(backupFile, encrypted, true, false); err != nil {
+		return errors.Wrap(err, "failed to write backup")
+	}
+
+	result := BackupDoc{File: backupFile, Params: len(archive.Params)}
+
+	return printResult("backup", config, result, func() {
+		fmt.Printf("wrote backup -> %s\n", backupFile)
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("params = %d", result.Params),
+			Config:  *config,
+		})
+	})
+}
+
+// snapshot reads all parameters under the prefix and all declared parameters
+func snapshot(ctx context.Context, st store.Store, config *c.Config) (*Archive, error) {
+	existing, err := st.GetByPath(ctx, config.Prefix)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list params by path")
+	}
+
+	declared, err := st.GetMany(ctx, config.All)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read params")
+	}
+
+	descriptions, err := descriptionsOf(ctx, st, config)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read descriptions")
+	}
+
+	archive := &Archive{
+		Version:      archiveVersion,
+		Created:      time.Now().UTC(),
+		Service:      config.Service,
+		Stage:        config.Stage,
+		Provider:     config.Provider,
+		Region:       config.Region,
+		Prefix:       config.Prefix,
+		SharedPrefix: config.SharedPrefix,
+		Params:       []ArchiveParam{},
+	}
+
+	seen := map[string]bool{}
+
+	for _, p := range append(declared, existing...) {
+		if seen[*p.Name] {
+			continue
+		}
+		seen[*p.Name] = true
+
+		tags, err := tagsOf(ctx, st, p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Description != "" {
+			descriptions[*p.Name] = p.Description
+		}
+
+		param := ArchiveParam{
+			Name:        *p.Name,
+			Value:       *p.Value,
+			Type:        p.Type,
+			DataType:    p.DataType,
+			Description: descriptions[*p.Name],
+			Version:     p.Version,
+			Modified:    p.Modified,
+			Secret:      p.Type == "SecureString",
+			Orphan:      true,
+			Tags:        tags,
+		}
+
+		for _, d := range config.All {
+			if d.Name == *p.Name {
+				param.Secret = d.Secret
+				param.Shared = d.Shared
+				param.Orphan = false
+
+				if param.Description == "" {
+					param.Description = d.Description
+				}
+				break
+			}
+		}
+
+		archive.Params = append(archive.Params, param)
+	}
+
+	return archive, nil
+}
+
+// descriptionsOf reads descriptions of params under the prefixes of the config
+// from stores that do not return them when listing
+func descriptionsOf(ctx context.Context, st store.Store, config *c.Config) (map[string]string, error) {
+	result := map[string]string{}
+
+	r, ok := st.(store.DescriptionReader)
+
+	if !ok {
+		return result, nil
+	}
+
+	paths := []string{config.Prefix}
+	for _, d := range config.All {
+		if d.Shared {
+			paths = append(paths, config.SharedPrefix)
+			break
+		}
+	}
+
+	for _, path := range paths {
+		descriptions, err := r.GetDescriptions(ctx, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for name, d := range descriptions {
+			result[name] = d
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/format"
+	"go/token"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const generatedHeader = "Code generated by safebox. DO NOT EDIT."
+
+type codegenField struct {
+	Name        string
+	Env         string
+	Type        string
+	Secret      bool
+	Description string
+}
+
+var identifierParts = regexp.MustCompile(`[A-Za-z0-9]+`)
+
+// codegenFields returns declared keys in a stable order, typed from the
+// `types` section of safebox config. Keys that map to the same environment
+// variable, eg. db-name and DB_NAME, fail.
+func codegenFields(in exportInput, typeNames map[string]string) ([]codegenField, error) {
+	keys := map[string]string{}
+	fields := []codegenField{}
+
+	for _, d := range in.declared {
+		key := d.Key()
+		env := envKey(key)
+
+		if other, ok := keys[env]; ok {
+			if other == key {
+				continue
+			}
+			return nil, errors.Errorf("%s and %s map to the same environment variable %s", other, key, env)
+		}
+		keys[env] = key
+
+		fields = append(fields, codegenField{
+			Env:         env,
+			Type:        typeNames[in.config.TypeOf(key)],
+			Secret:      d.Secret,
+			Description: d.Description,
+		})
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Env < fields[j].Env })
+
+	return fields, nil
+}
+
+// nameFields names fields after their environment variable, failing when two
+// variables get the same name
+func nameFields(fields []codegenField, name func(string) string) error {
+	names := map[string]string{}
+
+	for i, f := range fields {
+		fields[i].Name = name(f.Env)
+		if other, ok := names[fields[i].Name]; ok {
+			return errors.Errorf("%s and %s map to the same field %s", other, f.Env, fields[i].Name)
+		}
+		names[fields[i].Name] = f.Env
+	}
+
+	return nil
+}
+
+func pascalCase(key string) string {
+	var b strings.Builder
+	for _, p := range identifierParts.FindAllString(key, -1) {
+		b.WriteString(strings.ToUpper(p[:1]) + strings.ToLower(p[1:]))
+	}
+
+	name := b.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "X" + name
+	}
+	return name
+}
+
+// packageOf names the go package after the directory of the output file
+func packageOf(output string) string {
+	if output == "" {
+		return "config"
+	}
+
+	abs, err := filepath.Abs(output)
+	if err != nil {
+		return "config"
+	}
+
+	name := strings.ToLower(strings.Join(identifierParts.FindAllString(filepath.Base(filepath.Dir(abs)), -1), ""))
+	if !token.IsIdentifier(name) {
+		return "config"
+	}
+	return name
+}
+
+func snakeCase(key string) string {
+	name := strings.ToLower(strings.Join(identifierParts.FindAllString(key, -1), "_"))
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "x_" + name
+	}
+	return name
+}
+
+// pythonReserved are keywords of python, and model_config which would replace
+// the settings of pydantic
+var pythonReserved = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true,
+	"break": true, "class": true, "continue": true, "def": true, "del": true,
+	"elif": true, "else": true, "except": true, "finally": true, "for": true,
+	"from": true, "global": true, "if": true, "import": true, "in": true,
+	"is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true,
+	"with": true, "yield": true, "model_config": true,
+}
+
+// pythonName is the snake case attribute of a key, with _ appended to
+// reserved words
+func pythonName(key string) string {
+	name := snakeCase(key)
+	if pythonReserved[name] {
+		name += "_"
+	}
+	return name
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+var codegenFuncs = template.FuncMap{
+	"quote":   quote,
+	"oneline": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(codegenFuncs).Parse(`// ` + generatedHeader + `
+
+package {{.Package}}
+
+import (
+	"fmt"
+	"os"
+{{- if .Strconv}}
+	"strconv"
+{{- end}}
+	"strings"
+)
+
+type Config struct {
+{{- range .Fields}}
+{{- if .Description}}
+	// {{oneline .Description}}
+{{- end}}
+	{{.Name}} {{.Type}} ` + "`" + `env:"{{.Env}}"` + "`" + `
+{{- end}}
+}
+
+// Load reads Config from environment variables. It fails when any key is
+// missing, empty or cannot be parsed.
+func Load() (*Config, error) {
+	c := &Config{}
+	var missing []string
+{{- if .Strconv}}
+	var err error
+{{- end}}
+
+	lookup := func(key string) string {
+		v := os.Getenv(key)
+		if v == "" {
+			missing = append(missing, key)
+		}
+		return v
+	}
+{{range .Fields}}
+	if v := lookup({{quote .Env}}); v != "" {
+{{- if eq .Type "string"}}
+		c.{{.Name}} = v
+{{- else if eq .Type "int"}}
+		if c.{{.Name}}, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", {{quote .Env}}, err)
+		}
+{{- else if eq .Type "float64"}}
+		if c.{{.Name}}, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("%s: %w", {{quote .Env}}, err)
+		}
+{{- else if eq .Type "bool"}}
+		if c.{{.Name}}, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", {{quote .Env}}, err)
+		}
+{{- end}}
+	}
+{{end}}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing or empty environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	return c, nil
+}
+`))
+
+var pythonTemplate = template.Must(template.New("python").Funcs(codegenFuncs).Parse(`# ` + generatedHeader + `
+
+from pydantic import Field, SecretStr
+from pydantic_settings import BaseSettings, SettingsConfigDict
+
+
+class Settings(BaseSettings):
+    # empty variables are missing, as in the generated go and zod code
+    model_config = SettingsConfigDict(env_ignore_empty=True)
+{{- range .}}
+    {{.Name}}: {{if .Secret}}SecretStr{{else}}{{.Type}}{{end}} = Field(alias={{quote .Env}}{{if .Description}}, description={{quote .Description}}{{end}})
+{{- end}}
+
+
+def load() -> Settings:
+    return Settings()
+`))
+
+var zodTemplate = template.Must(template.New("zod").Funcs(codegenFuncs).Parse(`// ` + generatedHeader + `
+
+import { z } from "zod";
+
+export const envSchema = z.object({
+{{- range .}}
+  {{quote .Env}}: {{.Type}}{{if .Description}}.describe({{quote .Description}}){{end}},
+{{- end}}
+});
+
+export type Env = z.infer<typeof envSchema>;
+
+export function parseEnv(env: Record<string, string | undefined> = process.env): Env {
+  return envSchema.parse(env);
+}
+`))
+
+func exportAsTypesGo(in exportInput, w io.Writer) error {
+	fields, err := codegenFields(in, map[string]string{
+		"string": "string",
+		"int":    "int",
+		"float":  "float64",
+		"bool":   "bool",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !token.IsIdentifier(in.goPackage) {
+		return errors.Errorf("invalid go package name %q", in.goPackage)
+	}
+
+	if err := nameFields(fields, pascalCase); err != nil {
+		return err
+	}
+
+	data := struct {
+		Package string
+		Fields  []codegenField
+		Strconv bool
+	}{Package: in.goPackage, Fields: fields}
+
+	for _, f := range fields {
+		data.Strconv = data.Strconv || f.Type != "string"
+	}
+
+	var b bytes.Buffer
+	if err := goTemplate.Execute(&b, data); err != nil {
+		return err
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "failed to format generated go code")
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+func exportAsTypesPython(in exportInput, w io.Writer) error {
+	fields, err := codegenFields(in, map[string]string{
+		"string": "str",
+		"int":    "int",
+		"float":  "float",
+		"bool":   "bool",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := nameFields(fields, pythonName); err != nil {
+		return err
+	}
+
+	return pythonTemplate.Execute(w, fields)
+}
+
+func exportAsTypesZod(in exportInput, w io.Writer) error {
+	fields, err := codegenFields(in, map[string]string{
+		"string": "z.string().min(1)",
+		"int":    "z.string().min(1).pipe(z.coerce.number().int())",
+		"float":  "z.string().min(1).pipe(z.coerce.number())",
+		"bool":   `z.enum(["true", "false"]).transform((v) => v === "true")`,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return zodTemplate.Execute(w, fields)
+}
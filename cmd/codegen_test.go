@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+)
+
+func codegenInput(pkg string) exportInput {
+	return exportInput{
+		config: &c.Config{Types: map[string]string{"PORT": "int", "DEBUG": "bool"}},
+		declared: []store.ConfigInput{
+			{Name: "/dev/api/PORT"},
+			{Name: "/dev/api/DEBUG"},
+			{Name: "/dev/api/db-name", Description: "name of\nthe database"},
+			{Name: "/dev/shared/API_KEY", Secret: true, Shared: true},
+			{Name: "/dev/api/API_KEY", Secret: true},
+		},
+		goPackage: pkg,
+	}
+}
+
+func TestCodegen(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		pkg      string
+		contains []string
+		// once is declared under service and shared, and generated once
+		once    string
+		wantErr bool
+	}{
+		{
+			name:   "go",
+			format: "types-go",
+			pkg:    "settings",
+			once:   "`env:\"API_KEY\"`",
+			contains: []string{
+				"package settings\n",
+				"\t// name of the database\n\tDbName string `env:\"DB_NAME\"`",
+				"Port int `env:\"PORT\"`",
+				"Debug bool `env:\"DEBUG\"`",
+				"if c.Port, err = strconv.Atoi(v); err != nil {",
+				"if v == \"\" {\n\t\t\tmissing = append(missing, key)",
+			},
+		},
+		{name: "go invalid package", format: "types-go", pkg: "my-pkg", wantErr: true},
+		{
+			name:   "python",
+			format: "types-python",
+			once:   `alias="API_KEY"`,
+			contains: []string{
+				"model_config = SettingsConfigDict(env_ignore_empty=True)",
+				"    api_key: SecretStr = Field(alias=\"API_KEY\")\n",
+				"    port: int = Field(alias=\"PORT\")\n",
+				"    db_name: str = Field(alias=\"DB_NAME\", description=\"name of\\nthe database\")\n",
+			},
+		},
+		{
+			name:   "zod",
+			format: "types-zod",
+			once:   `"API_KEY":`,
+			contains: []string{
+				`"DB_NAME": z.string().min(1).describe("name of\nthe database"),`,
+				`"PORT": z.string().min(1).pipe(z.coerce.number().int()),`,
+				`"DEBUG": z.enum(["true", "false"]).transform((v) => v === "true"),`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			err := exporters[tt.format].write(codegenInput(tt.pkg), &b)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// generated code is formatted, compare ignoring alignment
+			got := strings.Join(strings.Fields(b.String()), " ")
+
+			for _, s := range tt.contains {
+				if !strings.Contains(got, strings.Join(strings.Fields(s), " ")) {
+					t.Errorf("output does not contain %q\n%s", s, b.String())
+				}
+			}
+
+			if n := strings.Count(b.String(), tt.once); tt.once != "" && n != 1 {
+				t.Errorf("%s is generated %d times, want once", tt.once, n)
+			}
+		})
+	}
+}
+
+func TestPackageOf(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "", want: "config"},
+		{output: "internal/config/config.go", want: "config"},
+		{output: "pkg/app-settings/env.go", want: "appsettings"},
+		{output: "v2/env.go", want: "v2"},
+		{output: "2fa/env.go", want: "config"},
+		{output: "type/env.go", want: "config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			if got := packageOf(tt.output); got != tt.want {
+				t.Errorf("packageOf(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodegenNameCollisions(t *testing.T) {
+	in := exportInput{
+		config:    &c.Config{},
+		declared:  []store.ConfigInput{{Name: "/dev/api/db-name"}, {Name: "/dev/api/DB_NAME"}},
+		goPackage: "config",
+	}
+
+	for _, format := range []string{"types-go", "types-python", "types-zod"} {
+		t.Run(format, func(t *testing.T) {
+			err := exporters[format].write(in, &bytes.Buffer{})
+
+			if err == nil || !strings.Contains(err.Error(), "same environment variable DB_NAME") {
+				t.Errorf("write() error = %v, want collision of DB_NAME", err)
+			}
+		})
+	}
+}
+
+func TestPythonName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "DB_NAME", want: "db_name"},
+		{key: "CLASS", want: "class_"},
+		{key: "FROM", want: "from_"},
+		{key: "IMPORT", want: "import_"},
+		{key: "MODEL_CONFIG", want: "model_config_"},
+		{key: "PASSWORD", want: "password"},
+		{key: "2FA", want: "x_2fa"},
+	}
+
+	for _, tt := range tests {
+		if got := pythonName(tt.key); got != tt.want {
+			t.Errorf("pythonName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
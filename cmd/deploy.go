@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 
+	"github.com/adikari/safebox/v2/audit"
+	c "github.com/adikari/safebox/v2/config"
 	"github.com/adikari/safebox/v2/store"
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
@@ -10,8 +16,13 @@ import (
 )
 
 var (
-	removeOrphans bool
-	prompt        string
+	removeOrphans  bool
+	assumeYes      bool
+	maxDelete      int
+	prompt         string
+	secretsFile    string
+	useEditor      bool
+	confirmSecrets bool
 
 	deployCmd = &cobra.Command{
 		Use:   "deploy",
@@ -20,14 +31,34 @@ var (
 	}
 )
 
+type DeployDoc struct {
+	Deployed       []string       `json:"deployed" yaml:"deployed"`
+	OrphansRemoved []string       `json:"orphansRemoved,omitempty" yaml:"orphansRemoved,omitempty"`
+	OrphanError    string         `json:"orphanError,omitempty" yaml:"orphanError,omitempty"`
+	Generated      []GeneratedDoc `json:"generated,omitempty" yaml:"generated,omitempty"`
+}
+
+type GeneratedDoc struct {
+	Type  string `json:"type" yaml:"type"`
+	Path  string `json:"path" yaml:"path"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
 func init() {
 	rootCmd.AddCommand(deployCmd)
 	deployCmd.Flags().BoolVarP(&removeOrphans, "remove-orphans", "r", false, "remove orphan configurations")
+	deployCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "remove orphans without confirmation")
+	deployCmd.Flags().IntVar(&maxDelete, "max-delete", 0, "most orphans to remove at once (default from config file or 10)")
 	deployCmd.Flags().StringVarP(&prompt, "prompt", "p", "", "prompt for configurations (missing or all)")
+	deployCmd.Flags().StringVar(&secretsFile, "secrets-file", "", "json or yaml file with secret values, - for stdin. .gpg and .asc files are decrypted with gpg")
+	deployCmd.Flags().BoolVar(&useEditor, "editor", false, "enter prompted secrets in $EDITOR")
+	deployCmd.Flags().BoolVar(&confirmSecrets, "confirm", false, "enter prompted secrets twice")
+	deployCmd.MarkFlagFilename("secrets-file")
 }
 
-func deploy(_ *cobra.Command, _ []string) error {
-	config, err := loadConfig()
+func deploy(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
 
 	if prompt != "" && prompt != "all" && prompt != "missing" {
 		return errors.New("value for prompt must be \"all\" or \"missing\"")
@@ -37,51 +68,71 @@ func deploy(_ *cobra.Command, _ []string) error {
 		return errors.Wrap(err, "failed to load config")
 	}
 
-	st, err := store.GetStore(store.StoreConfig{
+	st, err := store.GetStore(ctx, store.StoreConfig{
 		Provider: config.Provider,
 		Region:   config.Region,
 		FilePath: config.Filepath,
+		Session:  config.Session,
 	})
 
 	if err != nil {
 		return errors.Wrap(err, "failed to instantiate store")
 	}
 
-	all, err := st.GetMany(config.All)
+	all, err := st.GetMany(ctx, config.All)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to read existing params")
 	}
 
-	missing := getMissing(config.Secrets, all)
+	provided, err := providedSecrets(config.Secrets)
 
-	if len(missing) > 0 && prompt == "" {
-		return errors.New("config values missing. run deploy with \"--prompt\" flag")
+	if err != nil {
+		return errors.Wrap(err, "failed to read provided secrets")
 	}
 
 	configsToDeploy := []store.ConfigInput{}
 
+	// secrets provided through environment, secrets file or stdin
+	for _, c := range provided {
+		if existing := findConfig(c.Name, all); existing == nil || *existing.Value != c.Value {
+			configsToDeploy = append(configsToDeploy, c)
+		}
+	}
+
+	missing := getMissing(config.Secrets, all)
+	missing = excludeInputs(missing, provided)
+
+	if len(missing) > 0 && prompt == "" {
+		return errors.New("config values missing. run deploy with \"--prompt\" flag or provide them with SAFEBOX_SECRET_<KEY> or \"--secrets-file\"")
+	}
+
 	// prompt for missing secrets
 	if prompt == "missing" {
 		for _, c := range missing {
 			if c.Value == "" {
-				configsToDeploy = append(configsToDeploy, promptConfig(c))
+				input, err := promptConfig(c)
+				if err != nil {
+					return err
+				}
+				configsToDeploy = append(configsToDeploy, input)
 			}
 		}
 	}
 
 	// prompt for all secrets and provide existing value as default
 	if prompt == "all" {
-		for _, c := range config.Secrets {
+		for _, c := range excludeInputs(config.Secrets, provided) {
 			var existingValue string
-			for _, a := range all {
-				if c.Name == *a.Name {
-					existingValue = *a.Value
-					c.Value = *a.Value
-				}
+			if a := findConfig(c.Name, all); a != nil {
+				existingValue = *a.Value
+				c.Value = *a.Value
 			}
 
-			userInput := promptConfig(c)
+			userInput, err := promptConfig(c)
+			if err != nil {
+				return err
+			}
 
 			if userInput.Value != existingValue {
 				configsToDeploy = append(configsToDeploy, userInput)
@@ -108,78 +159,118 @@ func deploy(_ *cobra.Command, _ []string) error {
 		}
 	}
 
-	err = st.PutMany(configsToDeploy)
+	if err := checkSharedOwnership(ctx, st, config, all, configsToDeploy); err != nil {
+		return err
+	}
+
+	err = st.PutMany(ctx, configsToDeploy)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to write params")
 	}
 
+	recordAudit(ctx, st, config, audit.ActionDeploy, configsToDeploy)
+
+	if err := recordSharedUsage(ctx, st, config, all); err != nil {
+		return errors.Wrap(err, "failed to record usage of shared params")
+	}
+
+	result := DeployDoc{Deployed: []string{}}
+	for _, c := range configsToDeploy {
+		result.Deployed = append(result.Deployed, c.Name)
+	}
+
+	var orphanErr error
+
 	if removeOrphans {
-		orphans, err := doRemoveOrphans(st, config.Prefix, config.All)
+		orphans, err := doRemoveOrphans(ctx, st, config)
 		if err != nil {
-			fmt.Printf("%s\n", errors.Wrap(err, "Error: failed to remove orphan"))
+			orphanErr = errors.Wrap(err, "failed to remove orphan")
+			result.OrphanError = orphanErr.Error()
 		}
 
-		fmt.Printf("orphans removed = %d.\n", len(orphans))
+		result.OrphansRemoved = []string{}
+		for _, o := range orphans {
+			result.OrphansRemoved = append(result.OrphansRemoved, o.Name)
+		}
 	}
 
-	if len(config.Generate) > 0 {
-		for _, t := range config.Generate {
-			err := exportToFile(ExportParams{
-				config: config,
-				format: t.Type,
-				output: t.Path,
-			})
-
-			if err != nil {
-				fmt.Printf("Error: failed to generate file type = %s, output = %s\n", t.Type, t.Path)
-				fmt.Printf("       %s\n", err)
-				continue
-			}
+	result.Generated = generateFiles(ctx, config)
 
-			fmt.Printf("wrote file -> %s\n", t.Path)
+	err = printResult("deploy", config, result, func() {
+		if result.OrphansRemoved != nil {
+			fmt.Printf("orphans removed = %d.\n", len(result.OrphansRemoved))
 		}
-	}
 
-	PrintSummary(Summary{
-		Message: fmt.Sprintf("%s = %d", "new configs", len(configsToDeploy)),
-		Config:  *config,
+		printGenerated(result.Generated)
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("%s = %d", "new configs", len(configsToDeploy)),
+			Config:  *config,
+		})
 	})
 
-	return nil
+	if err != nil {
+		return err
+	}
+
+	// params are deployed, but the deploy is incomplete
+	return orphanErr
 }
 
-func doRemoveOrphans(st store.Store, prefix string, all []store.ConfigInput) ([]store.ConfigInput, error) {
-	var orphans []store.ConfigInput
-	params, err := st.GetByPath(prefix)
+// generateFiles writes the files under `generate` in config
+func generateFiles(ctx context.Context, config *c.Config) []GeneratedDoc {
+	var generated []GeneratedDoc
+
+	for _, t := range config.Generate {
+		err := exportToFile(ctx, ExportParams{
+			config:     config,
+			format:     t.Type,
+			output:     t.Path,
+			template:   t.Template,
+			nested:     t.Nested,
+			expandJson: t.ExpandJson,
+			goPackage:  t.Package,
+			preferSvc:  t.PreferService,
+		})
+
+		g := GeneratedDoc{Type: t.Type, Path: t.Path}
+		if err != nil {
+			g.Error = err.Error()
+		}
 
-	if err != nil {
-		return nil, err
+		generated = append(generated, g)
 	}
 
-	for _, param := range params {
-		exists := false
+	return generated
+}
 
-		for _, config := range all {
-			if config.Name == *param.Name {
-				exists = true
-				break
-			}
+func printGenerated(generated []GeneratedDoc) {
+	for _, g := range generated {
+		if g.Error != "" {
+			fmt.Printf("Error: failed to generate file type = %s, output = %s\n", g.Type, g.Path)
+			fmt.Printf("       %s\n", g.Error)
+			continue
 		}
 
-		if !exists {
-			orphans = append(orphans, store.ConfigInput{Name: *param.Name})
-		}
+		fmt.Printf("wrote file -> %s\n", g.Path)
 	}
+}
 
-	if err = st.DeleteMany(orphans); err != nil {
-		return nil, err
+// promptConfig asks for the value of a secret. Typed values are masked.
+// Entering @<path> reads the value from a file and :edit opens $EDITOR,
+// which allows multiline values.
+func promptConfig(config store.ConfigInput) (store.ConfigInput, error) {
+	hint := "@<path> reads from a file, :edit opens $EDITOR"
+	if config.Description != "" {
+		hint = fmt.Sprintf("%s. %s", config.Description, hint)
 	}
+	fmt.Fprintln(os.Stderr, promptui.Styler(promptui.FGFaint)(hint))
 
-	return orphans, nil
-}
+	if useEditor {
+		return withValue(config, editValue)
+	}
 
-func promptConfig(config store.ConfigInput) store.ConfigInput {
 	validate := func(input string) error {
 		if len(input) < 1 {
 			return fmt.Errorf("%s must not be empty", config.Name)
@@ -191,13 +282,64 @@ func promptConfig(config store.ConfigInput) store.ConfigInput {
 		Label:    config.Key(),
 		Validate: validate,
 		Default:  config.Value,
+		Mask:     '*',
+		Stdout:   os.Stderr,
 	}
 
-	result, _ := prompt.Run()
+	result, err := prompt.Run()
+
+	if err != nil {
+		return config, errors.Wrap(err, "aborted")
+	}
+
+	switch {
+	case result == ":edit":
+		return withValue(config, editValue)
+	case strings.HasPrefix(result, "@"):
+		return withValue(config, func(_ string, _ string) (string, error) {
+			b, err := ioutil.ReadFile(result[1:])
+			return string(b), err
+		})
+	}
+
+	if confirmSecrets && result != config.Value {
+		again := promptui.Prompt{
+			Label:  fmt.Sprintf("Confirm %s", config.Key()),
+			Mask:   '*',
+			Stdout: os.Stderr,
+		}
+
+		confirmed, err := again.Run()
+
+		if err != nil {
+			return config, errors.Wrap(err, "aborted")
+		}
+
+		if confirmed != result {
+			fmt.Fprintln(os.Stderr, "values do not match, try again")
+			return promptConfig(config)
+		}
+	}
 
 	config.Value = result
 
-	return config
+	return config, nil
+}
+
+func withValue(config store.ConfigInput, read func(key string, value string) (string, error)) (store.ConfigInput, error) {
+	value, err := read(config.Key(), config.Value)
+
+	if err != nil {
+		return config, errors.Wrap(err, config.Key())
+	}
+
+	if value == "" {
+		return config, fmt.Errorf("%s must not be empty", config.Name)
+	}
+
+	config.Value = value
+
+	return config, nil
 }
 
 func getMissing(a []store.ConfigInput, b []store.Config) []store.ConfigInput {
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Shows changes deploy would make",
+	Long: `Compares the config file with the store and shows the changes deploy would
+make. Configs that are missing are added and changed configs are updated.
+Missing secrets are asked for by deploy, or read from SAFEBOX_SECRET_<KEY>.
+Orphans are removed by deploy with --remove-orphans.
+
+Unlike drift, diff exits with 0 when there are changes.`,
+	RunE: diff,
+}
+
+type DiffDoc struct {
+	Changes []DriftParamDoc `json:"changes" yaml:"changes"`
+}
+
+// diffOps are the changes of deploy by status of a parameter
+var diffOps = map[string]string{
+	StatusMissing:   "+",
+	StatusOutOfDate: "~",
+	StatusOrphan:    "-",
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+func diff(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	doc, err := stageDrift(ctx, config)
+
+	if err != nil {
+		return err
+	}
+
+	result := DiffDoc{Changes: doc.Params}
+
+	return printResult("diff", config, result, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+
+		fmt.Fprintln(w, "Change\tName\tValue\tDeclared")
+
+		for _, p := range result.Changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", diffOps[p.Status], p.Name, oneLine(p.Value), oneLine(p.Expected))
+		}
+		fmt.Fprintln(w, "---")
+		w.Flush()
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("changes = %d", len(result.Changes)),
+			Config:  *config,
+		})
+	})
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftStages    []string
+	driftAllStages bool
+	driftOrphans   bool
+
+	driftCmd = &cobra.Command{
+		Use:   "drift",
+		Short: "Reports parameters that differ from the config file",
+		Long: `Compares the config file with the store and reports configs changed outside
+of safebox, missing configs and secrets, and orphans. Exits with an error when
+any drift is found, eg. to run it on a schedule in CI. Drift exits with code 2,
+failing to check exits with 1.
+
+Use --output json for a machine readable report.`,
+		RunE: drift,
+	}
+)
+
+type DriftDoc struct {
+	Drift  bool            `json:"drift" yaml:"drift"`
+	Stages []StageDriftDoc `json:"stages" yaml:"stages"`
+}
+
+type StageDriftDoc struct {
+	Stage  string          `json:"stage" yaml:"stage"`
+	Drift  bool            `json:"drift" yaml:"drift"`
+	Params []DriftParamDoc `json:"params" yaml:"params"`
+}
+
+// DriftParamDoc is a drifted parameter with the value declared in config
+// file. Secrets only have an expected value when provided through
+// SAFEBOX_SECRET_<KEY>, and it is masked.
+type DriftParamDoc struct {
+	ParamDoc `yaml:",inline"`
+	Expected string `json:"expected,omitempty" yaml:"expected,omitempty"`
+}
+
+// driftExitCode tells drift apart from failures to check, which exit with 1
+const driftExitCode = 2
+
+func init() {
+	driftCmd.Flags().StringSliceVar(&driftStages, "stages", []string{}, "stages to check (default is --stage)")
+	driftCmd.Flags().BoolVar(&driftAllStages, "all-stages", false, "check all stages declared in config file")
+	driftCmd.Flags().BoolVar(&driftOrphans, "orphans", true, "report orphans as drift")
+
+	rootCmd.AddCommand(driftCmd)
+}
+
+func drift(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	stages := driftStages
+	if driftAllStages {
+		stages = config.DeclaredStages
+	}
+	if len(stages) == 0 {
+		stages = []string{config.Stage}
+	}
+
+	result := DriftDoc{Stages: []StageDriftDoc{}}
+	drifted := 0
+
+	for _, s := range stages {
+		cfg := config
+		if s != config.Stage {
+			if cfg, err = loadStageConfig(ctx, s); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("failed to load config of stage %s", s))
+			}
+		}
+
+		doc, err := stageDrift(ctx, cfg)
+
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to check stage %s", s))
+		}
+
+		drifted += len(doc.Params)
+		result.Drift = result.Drift || doc.Drift
+		result.Stages = append(result.Stages, doc)
+	}
+
+	err = printResult("drift", config, result, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+
+		fmt.Fprintln(w, "Stage\tName\tStatus\tValue\tExpected\tKind")
+
+		for _, s := range result.Stages {
+			for _, p := range s.Params {
+				kind := "config"
+				if p.Secret {
+					kind = "secret"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Stage, p.Name, p.Status, p.Value, p.Expected, kind)
+			}
+		}
+		fmt.Fprintln(w, "---")
+		w.Flush()
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("drifted parameters = %d", drifted),
+			Config:  *config,
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if result.Drift {
+		fmt.Fprintf(os.Stderr, "drift detected in %d parameters\n", drifted)
+		return exitCode(driftExitCode)
+	}
+
+	return nil
+}
+
+// stageDrift compares a stage with the store, the same way as list
+func stageDrift(ctx context.Context, config *c.Config) (StageDriftDoc, error) {
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return StageDriftDoc{}, errors.Wrap(err, "failed to instantiate store")
+	}
+
+	return storeDrift(ctx, st, config)
+}
+
+// storeDrift reports params of st that differ from config
+func storeDrift(ctx context.Context, st store.Store, config *c.Config) (StageDriftDoc, error) {
+	configs, err := st.GetMany(ctx, config.All)
+
+	if err != nil {
+		return StageDriftDoc{}, errors.Wrap(err, "failed to list params")
+	}
+
+	existing, err := st.GetByPath(ctx, config.Prefix)
+
+	if err != nil {
+		return StageDriftDoc{}, errors.Wrap(err, "failed to list params by path")
+	}
+
+	provided, err := providedSecrets(config.Secrets)
+
+	if err != nil {
+		return StageDriftDoc{}, errors.Wrap(err, "failed to read provided secrets")
+	}
+
+	doc := StageDriftDoc{Stage: config.Stage, Params: []DriftParamDoc{}}
+
+	for _, item := range listItems(configs, existing, config) {
+		p := DriftParamDoc{ParamDoc: item}
+
+		for _, d := range config.Configs {
+			if d.Name == item.Name {
+				p.Expected = d.Value
+			}
+		}
+
+		for _, d := range provided {
+			if d.Name != item.Name {
+				continue
+			}
+
+			p.Expected = maskValue(d.Value)
+
+			if current := findConfig(d.Name, configs); current != nil && *current.Value != d.Value {
+				p.Status = StatusOutOfDate
+			}
+		}
+
+		if p.Status == StatusPresent || (p.Status == StatusOrphan && !driftOrphans) {
+			continue
+		}
+
+		doc.Params = append(doc.Params, p)
+	}
+
+	sort.Slice(doc.Params, func(i, j int) bool { return doc.Params[i].Name < doc.Params[j].Name })
+	doc.Drift = len(doc.Params) > 0
+
+	return doc, nil
+}
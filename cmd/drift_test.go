@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/adikari/safebox/v2/store/storetest"
+)
+
+func TestStoreDrift(t *testing.T) {
+	defer func(o bool) { driftOrphans = o }(driftOrphans)
+
+	host := store.ConfigInput{Name: "/dev/api/HOST", Value: "localhost"}
+	port := store.ConfigInput{Name: "/dev/api/PORT", Value: "value of /dev/api/PORT"}
+	apiKey := store.ConfigInput{Name: "/dev/api/API_KEY", Secret: true}
+	token := store.ConfigInput{Name: "/dev/api/TOKEN", Secret: true}
+	dbPassword := store.ConfigInput{Name: "/dev/api/DB_PASSWORD", Secret: true}
+
+	config := &c.Config{
+		Stage:   "dev",
+		Prefix:  "/dev/api/",
+		Configs: []store.ConfigInput{host, port},
+		Secrets: []store.ConfigInput{apiKey, token, dbPassword},
+		All:     []store.ConfigInput{host, port, apiKey, token, dbPassword},
+	}
+
+	// TOKEN is provided with its deployed value, DB_PASSWORD with another value
+	t.Setenv("SAFEBOX_SECRET_TOKEN", "value of /dev/api/TOKEN")
+	t.Setenv("SAFEBOX_SECRET_DB_PASSWORD", "rotated")
+
+	tests := []struct {
+		name    string
+		orphans bool
+		want    map[string]DriftParamDoc
+	}{
+		{
+			name:    "with orphans",
+			orphans: true,
+			want: map[string]DriftParamDoc{
+				"/dev/api/HOST":        {ParamDoc: ParamDoc{Status: StatusOutOfDate, Value: "value of /dev/api/HOST"}, Expected: "localhost"},
+				"/dev/api/API_KEY":     {ParamDoc: ParamDoc{Status: StatusMissing, Secret: true}},
+				"/dev/api/DB_PASSWORD": {ParamDoc: ParamDoc{Status: StatusOutOfDate, Value: maskValue("value of /dev/api/DB_PASSWORD"), Secret: true}, Expected: maskValue("rotated")},
+				"/dev/api/OLD":         {ParamDoc: ParamDoc{Status: StatusOrphan, Value: "value of /dev/api/OLD"}},
+			},
+		},
+		{
+			name: "without orphans",
+			want: map[string]DriftParamDoc{
+				"/dev/api/HOST":        {ParamDoc: ParamDoc{Status: StatusOutOfDate, Value: "value of /dev/api/HOST"}, Expected: "localhost"},
+				"/dev/api/API_KEY":     {ParamDoc: ParamDoc{Status: StatusMissing, Secret: true}},
+				"/dev/api/DB_PASSWORD": {ParamDoc: ParamDoc{Status: StatusOutOfDate, Value: maskValue("value of /dev/api/DB_PASSWORD"), Secret: true}, Expected: maskValue("rotated")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driftOrphans = tt.orphans
+			st := store.NewSSMStoreWithClient(storetest.NewFakeSSM("/dev/api/HOST", "/dev/api/PORT", "/dev/api/TOKEN", "/dev/api/DB_PASSWORD", "/dev/api/OLD"))
+
+			doc, err := storeDrift(context.Background(), st, config)
+
+			if err != nil {
+				t.Fatalf("storeDrift() error = %v", err)
+			}
+
+			if !doc.Drift || doc.Stage != "dev" {
+				t.Errorf("storeDrift() = %+v, want drift of dev", doc)
+			}
+
+			got := map[string]DriftParamDoc{}
+			names := []string{}
+			for _, p := range doc.Params {
+				names = append(names, p.Name)
+				got[p.Name] = DriftParamDoc{
+					ParamDoc: ParamDoc{Status: p.Status, Value: p.Value, Secret: p.Secret},
+					Expected: p.Expected,
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("storeDrift() = %+v, want %+v", got, tt.want)
+			}
+
+			for i := 1; i < len(names); i++ {
+				if names[i-1] > names[i] {
+					t.Errorf("params are not sorted by name: %v", names)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreDriftInSync(t *testing.T) {
+	port := store.ConfigInput{Name: "/dev/api/PORT", Value: "value of /dev/api/PORT"}
+	config := &c.Config{Prefix: "/dev/api/", Configs: []store.ConfigInput{port}, All: []store.ConfigInput{port}}
+	st := store.NewSSMStoreWithClient(storetest.NewFakeSSM("/dev/api/PORT"))
+
+	doc, err := storeDrift(context.Background(), st, config)
+
+	if err != nil {
+		t.Fatalf("storeDrift() error = %v", err)
+	}
+
+	if doc.Drift || len(doc.Params) != 0 {
+		t.Errorf("storeDrift() = %+v, want no drift", doc)
+	}
+}
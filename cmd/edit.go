@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/adikari/safebox/v2/audit"
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	editKeys    []string
+	editConfigs bool
+	editYes     bool
+	editShared  bool
+
+	editCmd = &cobra.Command{
+		Use:   "edit",
+		Short: "Edits secrets in $EDITOR",
+		Long: `Opens declared secrets as a yaml document in $VISUAL or $EDITOR.
+
+Changed values are written and removed keys are deleted once the changes are
+confirmed. Protected keys are never deleted and shared keys are only deleted
+with --delete-shared. The document is kept in memory backed storage when available and
+is wiped when the editor exits.`,
+		RunE: edit,
+	}
+)
+
+type editDocument struct {
+	Service map[string]string `yaml:"service"`
+	Shared  map[string]string `yaml:"shared"`
+}
+
+type EditDoc struct {
+	Updated []string `json:"updated" yaml:"updated"`
+	Deleted []string `json:"deleted" yaml:"deleted"`
+}
+
+func init() {
+	editCmd.Flags().StringSliceVarP(&editKeys, "key", "k", []string{}, "only edit specified keys (default is all secrets)")
+	editCmd.Flags().BoolVar(&editConfigs, "configs", false, "also edit configs. changed configs are reverted by the next deploy")
+	editCmd.Flags().BoolVarP(&editYes, "yes", "y", false, "apply changes without confirmation")
+	editCmd.Flags().BoolVar(&editShared, "delete-shared", false, "allow deleting shared keys owned by the service")
+	editCmd.Flags().IntVar(&maxDelete, "max-delete", 0, "most keys to delete at once (default from config file or 10)")
+
+	rootCmd.AddCommand(editCmd)
+}
+
+func edit(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate store")
+	}
+
+	declared := config.Secrets
+	if editConfigs {
+		declared = config.All
+	}
+
+	declared, err = configsToExport(declared, editKeys)
+
+	if err != nil {
+		return err
+	}
+
+	existing, err := st.GetMany(ctx, declared)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read params")
+	}
+
+	content, err := editDocumentOf(config, declared, existing)
+
+	if err != nil {
+		return err
+	}
+
+	var toPut, toDelete []store.ConfigInput
+
+	for {
+		if content, err = editFile(fmt.Sprintf("safebox-%s-*.yml", config.Service), content); err != nil {
+			return errors.Wrap(err, "failed to edit")
+		}
+
+		toPut, toDelete, err = editChanges(content, declared, existing, editShared)
+
+		if err == nil {
+			break
+		}
+
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+
+		again, cerr := confirm("Edit again", false)
+
+		if cerr != nil || !again {
+			return errors.Wrap(err, "aborted")
+		}
+	}
+
+	if toDelete, err = deletableInputs(ctx, st, config, toDelete, existing); err != nil {
+		return err
+	}
+
+	if len(toPut) == 0 && len(toDelete) == 0 {
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+
+	for _, p := range toPut {
+		op := "~"
+		if findConfig(p.Name, existing) == nil {
+			op = "+"
+		}
+		fmt.Fprintf(os.Stderr, "%s %s\n", op, p.Name)
+	}
+	for _, d := range toDelete {
+		fmt.Fprintf(os.Stderr, "- %s\n", d.Name)
+	}
+
+	ok, err := confirm(fmt.Sprintf("Apply %d changes", len(toPut)+len(toDelete)), editYes)
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("aborted")
+	}
+
+	if err := st.PutMany(ctx, toPut); err != nil {
+		return errors.Wrap(err, "failed to write params")
+	}
+
+	recordAudit(ctx, st, config, audit.ActionSet, toPut)
+
+	if len(toDelete) > 0 {
+		if err := st.DeleteMany(ctx, toDelete); err != nil {
+			return errors.Wrap(err, "failed to delete params")
+		}
+
+		recordAudit(ctx, st, config, audit.ActionDelete, toDelete)
+	}
+
+	result := EditDoc{Updated: []string{}, Deleted: []string{}}
+	for _, p := range toPut {
+		result.Updated = append(result.Updated, p.Name)
+	}
+	for _, d := range toDelete {
+		result.Deleted = append(result.Deleted, d.Name)
+	}
+
+	return printResult("edit", config, result, func() {
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("updated = %d, deleted = %d", len(result.Updated), len(result.Deleted)),
+			Config:  *config,
+		})
+	})
+}
+
+// editDocumentOf renders declared keys with their stored values, grouped
+// under service and shared. Missing keys are left empty.
+func editDocumentOf(config *c.Config, declared []store.ConfigInput, existing []store.Config) ([]byte, error) {
+	service, shared := yaml.MapSlice{}, yaml.MapSlice{}
+
+	for _, d := range declared {
+		var value string
+		if e := findConfig(d.Name, existing); e != nil {
+			value = *e.Value
+		}
+
+		item := yaml.MapItem{Key: d.Key(), Value: value}
+		if d.Shared {
+			shared = append(shared, item)
+		} else {
+			service = append(service, item)
+		}
+	}
+
+	doc := yaml.MapSlice{}
+	if len(service) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "service", Value: service})
+	}
+	if len(shared) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "shared", Value: shared})
+	}
+
+	b, err := yaml.Marshal(doc)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create document")
+	}
+
+	header := fmt.Sprintf("# %s of %s. save and quit to apply.\n# remove a key to delete it. empty values of missing keys are ignored.\n", config.Provider, config.Prefix)
+
+	return append([]byte(header), b...), nil
+}
+
+// editChanges compares the edited document with stored values. Removing a
+// shared key fails unless deleteShared is set.
+func editChanges(content []byte, declared []store.ConfigInput, existing []store.Config, deleteShared bool) ([]store.ConfigInput, []store.ConfigInput, error) {
+	var doc editDocument
+
+	if err := yaml.UnmarshalStrict(content, &doc); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid document")
+	}
+
+	seen := map[string]bool{}
+	var toPut, toDelete []store.ConfigInput
+
+	for _, d := range declared {
+		group := doc.Service
+		if d.Shared {
+			group = doc.Shared
+		}
+
+		value, ok := group[d.Key()]
+		seen[groupKey(d.Shared, d.Key())] = true
+		current := findConfig(d.Name, existing)
+
+		switch {
+		case !ok && current != nil && d.Shared && !deleteShared:
+			return nil, nil, errors.Errorf("%s is shared. run with --delete-shared to delete it", groupKey(true, d.Key()))
+		case !ok && current != nil:
+			toDelete = append(toDelete, d)
+		case !ok || value == "" && current == nil:
+			continue
+		case value == "":
+			return nil, nil, errors.Errorf("%s must not be empty. remove the key to delete it", d.Key())
+		case current == nil || *current.Value != value:
+			d.Value = value
+			toPut = append(toPut, d)
+		}
+	}
+
+	for shared, group := range map[bool]map[string]string{false: doc.Service, true: doc.Shared} {
+		for key := range group {
+			if !seen[groupKey(shared, key)] {
+				return nil, nil, errors.Errorf("%s is not declared in config file", groupKey(shared, key))
+			}
+		}
+	}
+
+	return toPut, toDelete, nil
+}
+
+// deletableInputs applies the checks of orphan removal to removed keys,
+// keeping the declared options of those that may be deleted
+func deletableInputs(ctx context.Context, st store.Store, config *c.Config, toDelete []store.ConfigInput, existing []store.Config) ([]store.ConfigInput, error) {
+	if len(toDelete) == 0 {
+		return toDelete, nil
+	}
+
+	params := []store.Config{}
+	for _, d := range toDelete {
+		params = append(params, *findConfig(d.Name, existing))
+	}
+
+	allowed, err := deletable(ctx, st, config, params, deleteLimit(config))
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := []store.ConfigInput{}
+	for _, d := range toDelete {
+		for _, a := range allowed {
+			if a.Name == d.Name {
+				result = append(result, d)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func groupKey(shared bool, key string) string {
+	if shared {
+		return "shared." + key
+	}
+
+	return "service." + key
+}
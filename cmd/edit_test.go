@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adikari/safebox/v2/store"
+)
+
+func TestEditChanges(t *testing.T) {
+	declared := []store.ConfigInput{
+		{Name: "/dev/api/HOST"},
+		{Name: "/dev/api/PORT"},
+		{Name: "/dev/api/NEW"},
+		{Name: "/dev/shared/REGION", Shared: true},
+	}
+
+	existing := []store.Config{
+		param("/dev/api/HOST", "h"),
+		param("/dev/api/PORT", "80"),
+		param("/dev/shared/REGION", "r"),
+	}
+
+	tests := []struct {
+		name         string
+		content      string
+		deleteShared bool
+		wantPut      []string
+		wantDelete   []string
+		wantErr      bool
+	}{
+		{
+			name:    "unchanged",
+			content: "service:\n  HOST: h\n  PORT: \"80\"\nshared:\n  REGION: r\n",
+		},
+		{
+			name:    "changed value",
+			content: "service:\n  HOST: other\n  PORT: \"80\"\nshared:\n  REGION: r\n",
+			wantPut: []string{"/dev/api/HOST=other"},
+		},
+		{
+			name:    "new value",
+			content: "service:\n  HOST: h\n  PORT: \"80\"\n  NEW: n\nshared:\n  REGION: r\n",
+			wantPut: []string{"/dev/api/NEW=n"},
+		},
+		{
+			name:       "removed key",
+			content:    "service:\n  HOST: h\nshared:\n  REGION: r\n",
+			wantDelete: []string{"/dev/api/PORT"},
+		},
+		{
+			name:    "removed shared key",
+			content: "service:\n  HOST: h\n  PORT: \"80\"\n",
+			wantErr: true,
+		},
+		{
+			name:         "removed shared key with delete shared",
+			content:      "service:\n  HOST: h\n  PORT: \"80\"\n",
+			deleteShared: true,
+			wantDelete:   []string{"/dev/shared/REGION"},
+		},
+		{
+			name:    "empty value of existing key",
+			content: "service:\n  HOST: \"\"\n  PORT: \"80\"\nshared:\n  REGION: r\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty value of missing key",
+			content: "service:\n  HOST: h\n  PORT: \"80\"\n  NEW: \"\"\nshared:\n  REGION: r\n",
+		},
+		{
+			name:    "undeclared key",
+			content: "service:\n  HOST: h\n  PORT: \"80\"\n  OTHER: o\nshared:\n  REGION: r\n",
+			wantErr: true,
+		},
+		{
+			name:    "service key under shared",
+			content: "service:\n  HOST: h\n  PORT: \"80\"\nshared:\n  REGION: r\n  HOST: h\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown section",
+			content: "services:\n  HOST: h\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			content: "service: [",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toPut, toDelete, err := editChanges([]byte(tt.content), declared, existing, tt.deleteShared)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("editChanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			puts := []string{}
+			for _, p := range toPut {
+				puts = append(puts, p.Name+"="+p.Value)
+			}
+
+			deletes := []string{}
+			for _, d := range toDelete {
+				deletes = append(deletes, d.Name)
+			}
+
+			if tt.wantPut == nil {
+				tt.wantPut = []string{}
+			}
+
+			if tt.wantDelete == nil {
+				tt.wantDelete = []string{}
+			}
+
+			if !reflect.DeepEqual(puts, tt.wantPut) {
+				t.Errorf("put = %v, want %v", puts, tt.wantPut)
+			}
+
+			if !reflect.DeepEqual(deletes, tt.wantDelete) {
+				t.Errorf("delete = %v, want %v", deletes, tt.wantDelete)
+			}
+		})
+	}
+}
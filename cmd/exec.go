@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execWatch    bool
+	execSignal   string
+	execInterval time.Duration
+
+	execCmd = &cobra.Command{
+		Use:   "exec -- <command> [args...]",
+		Short: "Runs a command with configurations as environment variables",
+		Long: `Runs a command with configurations as environment variables. Keys are
+upper cased and - is replaced with _.
+
+With --watch the command is restarted when a value or the config file changes,
+which are polled every --interval as in watch.
+With --signal it is sent the signal instead, eg. for processes that reload
+files written by generate.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: execE,
+	}
+)
+
+// stopTimeout is how long a process is given to exit before it is killed
+const stopTimeout = 10 * time.Second
+
+func init() {
+	execCmd.Flags().BoolVarP(&execWatch, "watch", "w", false, "restart the command when configurations change")
+	execCmd.Flags().StringVar(&execSignal, "signal", "", "with --watch, send the signal instead of restarting, eg. HUP")
+	execCmd.Flags().DurationVar(&execInterval, "interval", 10*time.Second, "with --watch, how often to poll the store")
+
+	rootCmd.AddCommand(execCmd)
+}
+
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+	err    error
+}
+
+func execE(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	var sig os.Signal
+	if execSignal != "" {
+		var err error
+		if sig, err = parseSignal(execSignal); err != nil {
+			return err
+		}
+	}
+
+	if !execWatch {
+		config, st, err := loadWatched(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		configs, err := st.GetMany(ctx, config.All)
+
+		if err != nil {
+			return err
+		}
+
+		p, err := startProcess(args, configs, config.All)
+
+		if err != nil {
+			return err
+		}
+
+		<-p.exited
+		return exitCodeOf(p.err)
+	}
+
+	var mu sync.Mutex
+	var current *process
+	var exited *process
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	err := watchConfigs(ctx, execInterval, func(config *c.Config, configs []store.Config) error {
+		mu.Lock()
+		p := current
+		mu.Unlock()
+
+		if p != nil && sig != nil {
+			fmt.Fprintf(os.Stderr, "configurations changed, sending %s\n", execSignal)
+			return p.cmd.Process.Signal(sig)
+		}
+
+		if p != nil {
+			fmt.Fprintln(os.Stderr, "configurations changed, restarting")
+
+			mu.Lock()
+			current = nil
+			mu.Unlock()
+
+			p.stop()
+		}
+
+		p, err := startProcess(args, configs, config.All)
+
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		current = p
+		mu.Unlock()
+
+		// stop watching when the process exits on its own
+		go func() {
+			<-p.exited
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if current == p {
+				exited = p
+				cancel()
+			}
+		}()
+
+		return nil
+	})
+
+	mu.Lock()
+	p, done := current, exited
+	mu.Unlock()
+
+	if done != nil {
+		return exitCodeOf(done.err)
+	}
+
+	if p != nil {
+		// interrupted, the process got the signal from the terminal as well
+		p.wait()
+		return exitCodeOf(p.err)
+	}
+
+	return err
+}
+
+func startProcess(args []string, configs []store.Config, declared []store.ConfigInput) (*process, error) {
+	params, collisions := flatten(configs, declared)
+
+	if len(collisions) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: declared in both shared and service, using service values: %s\n", strings.Join(collisions, ", "))
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+
+	for _, k := range sortedKeys(params) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", envKey(k), params[k]))
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &process{cmd: cmd, exited: make(chan struct{})}
+
+	forward := make(chan os.Signal, 1)
+	signal.Notify(forward, syscall.SIGTERM, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case s := <-forward:
+				cmd.Process.Signal(s)
+			case <-p.exited:
+				signal.Stop(forward)
+				return
+			}
+		}
+	}()
+
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+
+	return p, nil
+}
+
+// stop terminates the process, killing it after stopTimeout
+func (p *process) stop() {
+	p.cmd.Process.Signal(syscall.SIGTERM)
+	p.wait()
+}
+
+func (p *process) wait() {
+	select {
+	case <-p.exited:
+	case <-time.After(stopTimeout):
+		p.cmd.Process.Kill()
+		<-p.exited
+	}
+}
+
+func exitCodeOf(err error) error {
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code > 0 {
+			return exitCode(code)
+		}
+		return exitCode(1)
+	}
+
+	return err
+}
+
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(name), "SIG")
+
+	signals := map[string]syscall.Signal{
+		"HUP":  syscall.SIGHUP,
+		"INT":  syscall.SIGINT,
+		"TERM": syscall.SIGTERM,
+	}
+
+	for k, v := range platformSignals {
+		signals[k] = v
+	}
+
+	if s, ok := signals[name]; ok {
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("unsupported signal `%s`", name)
+}
@@ -1,14 +1,17 @@
 package cmd
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/template"
 
 	c "github.com/adikari/safebox/v2/config"
 	"github.com/adikari/safebox/v2/store"
@@ -23,6 +26,10 @@ var (
 	exportFormat string
 	outputFile   string
 	keysToExport []string
+	nested       bool
+	expandJson   bool
+	goPackage    string
+	preferSvc    bool
 
 	exportCmd = &cobra.Command{
 		Use:   "export",
@@ -32,26 +39,35 @@ var (
 )
 
 func init() {
-	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "json", "output format (json, yaml, dotenv, types-node)")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "json", fmt.Sprintf("output format (%s)", strings.Join(exportFormats(), ", ")))
 	exportCmd.Flags().StringVarP(&outputFile, "output-file", "o", "", "output file (default is standard output)")
 	exportCmd.Flags().StringSliceVarP(&keysToExport, "key", "k", []string{}, "only export specified config (default is export all)")
+	exportCmd.Flags().BoolVar(&nested, "nested", false, "group keys under shared and service (json, yaml only)")
+	exportCmd.Flags().BoolVar(&expandJson, "expand-json", false, "export json values as objects (json, yaml only)")
+	exportCmd.Flags().BoolVar(&preferSvc, "prefer-service", false, "export the service value of keys declared in both shared and service")
+	exportCmd.Flags().StringVar(&goPackage, "package", "", "package of generated go code (default is the directory of output file, or config)")
 	exportCmd.MarkFlagFilename("output-file")
 
 	rootCmd.AddCommand(exportCmd)
 }
 
-func export(_ *cobra.Command, _ []string) error {
-	config, err := loadConfig()
+func export(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to load config")
 	}
 
-	return exportToFile(ExportParams{
+	return exportToFile(ctx, ExportParams{
 		config:       config,
 		keysToExport: keysToExport,
 		format:       exportFormat,
 		output:       outputFile,
+		nested:       nested,
+		expandJson:   expandJson,
+		goPackage:    goPackage,
+		preferSvc:    preferSvc,
 	})
 }
 
@@ -60,78 +76,208 @@ type ExportParams struct {
 	keysToExport []string
 	format       string
 	output       string
+	template     string
+	nested       bool
+	expandJson   bool
+	goPackage    string
+	preferSvc    bool
 }
 
-func exportToFile(p ExportParams) error {
-	store, err := store.GetStore(store.StoreConfig{
+func exportToFile(ctx context.Context, p ExportParams) error {
+	store, err := store.GetStore(ctx, store.StoreConfig{
 		Provider: p.config.Provider,
 		Region:   p.config.Region,
 		FilePath: p.config.Filepath,
+		Session:  p.config.Session,
 	})
 
 	if err != nil {
 		return errors.Wrap(err, "failed to instantiate store")
 	}
 
+	format := strings.ToLower(p.format)
+	exp, ok := exporters[format]
+
+	if !ok {
+		return errors.Errorf("unsupported export format: %s", p.format)
+	}
+
+	if (p.nested || p.expandJson) && !exp.structured {
+		return errors.Errorf("nested and expand-json are not supported by %s format", p.format)
+	}
+
 	toExport, err := configsToExport(p.config.All, p.keysToExport)
 
 	if err != nil {
 		return err
 	}
 
-	configs, err := store.GetMany(toExport)
+	configs, err := store.GetMany(ctx, toExport)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to get params")
 	}
 
-	file := os.Stdout
-	if p.output != "" {
-		directory := filepath.Dir(p.output)
+	in := exportInput{config: p.config, declared: toExport, goPackage: p.goPackage}
 
-		if _, err := os.Stat(directory); errors.Is(err, os.ErrNotExist) {
-			err := os.MkdirAll(directory, os.ModePerm)
-			if err != nil {
-				return errors.Wrap(err, "failed to write file")
-			}
+	if in.goPackage == "" {
+		in.goPackage = packageOf(p.output)
+	}
+
+	var collisions []string
+
+	if in.params, collisions = flatten(configs, toExport); len(collisions) > 0 && !p.nested && !p.preferSvc {
+		return collisionError(collisions, exp.structured)
+	}
+
+	if p.nested || p.expandJson {
+		in.document = structure(configs, toExport, p.nested, p.expandJson)
+	}
+
+	if format == "template" {
+		if in.template, err = parseTemplate(p.template); err != nil {
+			return err
 		}
+	}
+
+	output := p.output
+	appendOnly := false
+	if output == "" && exp.appendToEnv != "" {
+		output = os.Getenv(exp.appendToEnv)
+		appendOnly = output != ""
+	}
+
+	// render before touching the output, so a failure keeps the previous file
+	var b bytes.Buffer
+
+	if err = exp.write(in, &b); err != nil {
+		return errors.Wrap(err, "failed to export parameters")
+	}
 
-		if file, err = os.OpenFile(p.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+	if output == "" {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+
+	return writeOutputFile(output, b.Bytes(), hasSecrets(toExport), appendOnly)
+}
+
+// collisionError refuses to drop shared values of keys declared in both shared
+// and service, unless the service values are preferred
+func collisionError(collisions []string, structured bool) error {
+	hint := "use --prefer-service to export the service values"
+	if structured {
+		hint += ", or --nested to export both"
+	}
+
+	return errors.Errorf("declared in both shared and service: %s. %s", strings.Join(collisions, ", "), hint)
+}
+
+// writeOutputFile writes data to path, creating parent directories as needed.
+// Files that will contain secrets are only readable by the current user.
+// The file is replaced through a temporary file in the same directory, so it
+// is never left partly written. When appendOnly is set data is appended and
+// the mode of the file left untouched.
+func writeOutputFile(path string, data []byte, secret bool, appendOnly bool) error {
+	directory := filepath.Dir(path)
+
+	if _, err := os.Stat(directory); errors.Is(err, os.ErrNotExist) {
+		err := os.MkdirAll(directory, os.ModePerm)
+		if err != nil {
+			return errors.Wrap(err, "failed to write file")
+		}
+	}
+
+	var mode os.FileMode = 0644
+	if secret {
+		mode = 0600
+	}
+
+	if appendOnly {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, mode)
+		if err != nil {
 			return errors.Wrap(err, "failed to open output file for writing")
 		}
 
-		defer file.Close()
-		defer file.Sync()
+		// a single write keeps concurrent appends from interleaving
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return errors.Wrap(err, "failed to write output file")
+		}
+
+		return file.Close()
 	}
 
-	w := bufio.NewWriter(file)
-	defer w.Flush()
+	file, err := ioutil.TempFile(directory, "."+filepath.Base(path)+"-*")
 
-	params := map[string]string{}
-	for _, c := range configs {
-		params[c.Key()] = *c.Value
+	if err != nil {
+		return errors.Wrap(err, "failed to open output file for writing")
 	}
 
-	switch strings.ToLower(p.format) {
-	case "json":
-		err = exportAsJson(params, w)
-	case "yaml":
-		err = exportAsYaml(params, w)
-	case "dotenv":
-		err = exportAsEnvFile(params, w)
-	case "types-node":
-		err = exportAsTypesNode(params, w)
-	default:
-		err = errors.Errorf("unsupported export format: %s", exportFormat)
+	tmp := file.Name()
+	defer os.Remove(tmp)
+
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to set output file permissions")
 	}
 
-	if err != nil {
-		return errors.Wrap(err, "failed to export parameters")
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to write output file")
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to write output file")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "failed to write output file")
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrap(err, "failed to replace output file")
 	}
 
 	return nil
 }
 
+func parseTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, errors.New("template file is required for template format")
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").ParseFiles(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse template")
+	}
+
+	return tmpl, nil
+}
+
+func exportAsTemplate(in exportInput, w io.Writer) error {
+	var b bytes.Buffer
+
+	if err := in.template.Execute(&b, in.data()); err != nil {
+		return err
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func hasSecrets(configs []store.ConfigInput) bool {
+	for _, c := range configs {
+		if c.Secret {
+			return true
+		}
+	}
+
+	return false
+}
+
 func exportAsTypesNode(params map[string]string, w io.Writer) error {
 	w.Write([]byte(fmt.Sprintf("declare global {\n")))
 	w.Write([]byte(fmt.Sprintf("  namespace NodeJS {\n")))
@@ -152,14 +298,91 @@ func exportAsTypesNode(params map[string]string, w io.Writer) error {
 
 func exportAsEnvFile(params map[string]string, w io.Writer) error {
 	for _, k := range sortedKeys(params) {
-		key := strings.ToUpper(k)
-		key = strings.Replace(key, "-", "_", -1)
+		key := envKey(k)
 		w.Write([]byte(fmt.Sprintf(`%s="%s"`+"\n", key, doubleQuoteEscape(params[k]))))
 	}
 	return nil
 }
 
-func exportAsJson(params map[string]string, w io.Writer) error {
+// flatten maps configs by key. When a key is declared under both shared and
+// service the service value is kept and the key returned as a collision.
+func flatten(configs []store.Config, declared []store.ConfigInput) (map[string]string, []string) {
+	params := map[string]string{}
+	fromShared := map[string]bool{}
+	collisions := []string{}
+
+	for _, c := range configs {
+		key := c.Key()
+		shared := isShared(*c.Name, declared)
+
+		if wasShared, ok := fromShared[key]; ok {
+			collisions = append(collisions, key)
+
+			if !wasShared || shared {
+				continue
+			}
+		}
+
+		fromShared[key] = shared
+		params[key] = *c.Value
+	}
+
+	sort.Strings(collisions)
+
+	return params, collisions
+}
+
+func isShared(name string, declared []store.ConfigInput) bool {
+	for _, d := range declared {
+		if d.Name == name {
+			return d.Shared
+		}
+	}
+
+	return false
+}
+
+func structure(configs []store.Config, declared []store.ConfigInput, nested bool, expandJson bool) map[string]interface{} {
+	doc := map[string]interface{}{}
+	shared := map[string]interface{}{}
+	service := map[string]interface{}{}
+
+	for _, c := range configs {
+		var value interface{} = *c.Value
+
+		if expandJson {
+			var v interface{}
+			trimmed := strings.TrimSpace(*c.Value)
+			isJson := strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")
+			if isJson && json.Unmarshal([]byte(trimmed), &v) == nil {
+				value = v
+			}
+		}
+
+		if !nested {
+			// service values take precedence, as in flat exports
+			if _, ok := doc[c.Key()]; !ok || !isShared(*c.Name, declared) {
+				doc[c.Key()] = value
+			}
+			continue
+		}
+
+		group := service
+		if isShared(*c.Name, declared) {
+			group = shared
+		}
+		group[c.Key()] = value
+	}
+
+	if nested {
+		doc["shared"] = shared
+		doc["service"] = service
+	}
+
+	return doc
+}
+
+func exportAsJson(params interface{}, w io.Writer) error {
 	d, err := json.MarshalIndent(params, "", "  ")
 	if err != nil {
 		return err
@@ -168,7 +391,7 @@ func exportAsJson(params map[string]string, w io.Writer) error {
 	return nil
 }
 
-func exportAsYaml(params map[string]string, w io.Writer) error {
+func exportAsYaml(params interface{}, w io.Writer) error {
 	return yaml.NewEncoder(w).Encode(params)
 }
 
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adikari/safebox/v2/store"
+)
+
+func param(name string, value string) store.Config {
+	return store.Config{Name: &name, Value: &value}
+}
+
+func TestFlatten(t *testing.T) {
+	declared := []store.ConfigInput{
+		{Name: "/dev/api/HOST"},
+		{Name: "/dev/api/API_KEY", Secret: true},
+		{Name: "/dev/shared/API_KEY", Secret: true, Shared: true},
+		{Name: "/dev/shared/REGION", Shared: true},
+	}
+
+	tests := []struct {
+		name           string
+		configs        []store.Config
+		want           map[string]string
+		wantCollisions []string
+	}{
+		{
+			name:           "no collisions",
+			configs:        []store.Config{param("/dev/api/HOST", "h"), param("/dev/shared/REGION", "r")},
+			want:           map[string]string{"HOST": "h", "REGION": "r"},
+			wantCollisions: []string{},
+		},
+		{
+			name:           "service after shared",
+			configs:        []store.Config{param("/dev/shared/API_KEY", "shared"), param("/dev/api/API_KEY", "service")},
+			want:           map[string]string{"API_KEY": "service"},
+			wantCollisions: []string{"API_KEY"},
+		},
+		{
+			name:           "service before shared",
+			configs:        []store.Config{param("/dev/api/API_KEY", "service"), param("/dev/shared/API_KEY", "shared")},
+			want:           map[string]string{"API_KEY": "service"},
+			wantCollisions: []string{"API_KEY"},
+		},
+		{
+			name:           "undeclared are service",
+			configs:        []store.Config{param("/dev/shared/REGION", "shared"), param("/dev/api/REGION", "service")},
+			want:           map[string]string{"REGION": "service"},
+			wantCollisions: []string{"REGION"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, collisions := flatten(tt.configs, declared)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flatten() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(collisions, tt.wantCollisions) {
+				t.Errorf("flatten() collisions = %v, want %v", collisions, tt.wantCollisions)
+			}
+		})
+	}
+}
+
+func TestStructure(t *testing.T) {
+	declared := []store.ConfigInput{
+		{Name: "/dev/api/API_KEY"},
+		{Name: "/dev/shared/API_KEY", Shared: true},
+	}
+	configs := []store.Config{param("/dev/api/API_KEY", `{"a":1}`), param("/dev/shared/API_KEY", "shared")}
+
+	tests := []struct {
+		name       string
+		nested     bool
+		expandJson bool
+		want       map[string]interface{}
+	}{
+		{
+			name: "flat",
+			want: map[string]interface{}{"API_KEY": `{"a":1}`},
+		},
+		{
+			name:       "flat expanded",
+			expandJson: true,
+			want:       map[string]interface{}{"API_KEY": map[string]interface{}{"a": float64(1)}},
+		},
+		{
+			name:   "nested",
+			nested: true,
+			want: map[string]interface{}{
+				"service": map[string]interface{}{"API_KEY": `{"a":1}`},
+				"shared":  map[string]interface{}{"API_KEY": "shared"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := structure(configs, declared, tt.nested, tt.expandJson); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("structure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string
+		data       string
+		secret     bool
+		appendOnly bool
+		want       string
+		wantMode   os.FileMode
+	}{
+		{name: "creates file", data: "A=1\n", want: "A=1\n", wantMode: 0644},
+		{name: "creates secret file", data: "A=1\n", secret: true, want: "A=1\n", wantMode: 0600},
+		{name: "replaces file", existing: "OLD=1\n", data: "A=1\n", want: "A=1\n", wantMode: 0644},
+		{name: "appends", existing: "OLD=1\n", data: "A=1\n", appendOnly: true, want: "OLD=1\nA=1\n", wantMode: 0644},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "nested", "out.env")
+
+			if tt.existing != "" {
+				os.MkdirAll(filepath.Dir(path), 0755)
+				if err := ioutil.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeOutputFile(path, []byte(tt.data), tt.secret, tt.appendOnly); err != nil {
+				t.Fatalf("writeOutputFile() error = %v", err)
+			}
+
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+
+			fi, _ := os.Stat(path)
+			if fi.Mode().Perm() != tt.wantMode {
+				t.Errorf("mode = %v, want %v", fi.Mode().Perm(), tt.wantMode)
+			}
+
+			entries, _ := ioutil.ReadDir(filepath.Dir(path))
+			if len(entries) != 1 {
+				t.Errorf("temporary files left in %s: %d entries", filepath.Dir(path), len(entries))
+			}
+		})
+	}
+}
+
+func TestCollisionError(t *testing.T) {
+	err := collisionError([]string{"API_KEY"}, false)
+
+	if want := "declared in both shared and service: API_KEY. use --prefer-service to export the service values"; err.Error() != want {
+		t.Errorf("flat format error = %q, want %q", err, want)
+	}
+
+	if err := collisionError([]string{"API_KEY"}, true); !strings.HasSuffix(err.Error(), "or --nested to export both") {
+		t.Errorf("structured format error = %q, want hint of --nested", err)
+	}
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+type exportInput struct {
+	config   *c.Config
+	declared []store.ConfigInput
+	params   map[string]string
+	document map[string]interface{}
+	template *template.Template
+	// goPackage is the package of generated go code
+	goPackage string
+}
+
+// data returns the structured document when requested, otherwise flat params
+func (in exportInput) data() interface{} {
+	if in.document != nil {
+		return in.document
+	}
+	return in.params
+}
+
+type exporter struct {
+	write func(in exportInput, w io.Writer) error
+	// appendToEnv names an environment variable holding a file which output
+	// is appended to when no output file is given
+	appendToEnv string
+	// structured formats support nested and expanded json values
+	structured bool
+}
+
+// exporters holds all supported export formats. Register new formats here.
+var exporters = map[string]exporter{
+	"json":           {write: document(exportAsJson), structured: true},
+	"yaml":           {write: document(exportAsYaml), structured: true},
+	"dotenv":         {write: paramsOnly(exportAsEnvFile)},
+	"types-node":     {write: paramsOnly(exportAsTypesNode)},
+	"shell":          {write: paramsOnly(exportAsShell)},
+	"fish":           {write: paramsOnly(exportAsFish)},
+	"properties":     {write: paramsOnly(exportAsProperties)},
+	"toml":           {write: paramsOnly(exportAsToml)},
+	"ini":            {write: paramsOnly(exportAsIni)},
+	"tfvars":         {write: paramsOnly(exportAsTfvars)},
+	"docker-env":     {write: paramsOnly(exportAsDockerEnv)},
+	"systemd":        {write: paramsOnly(exportAsSystemd)},
+	"github-actions": {write: paramsOnly(exportAsGithubActions), appendToEnv: "GITHUB_ENV"},
+	"k8s-secret":     {write: exportAsK8sSecret},
+	"k8s-configmap":  {write: exportAsK8sConfigMap},
+	"types-go":       {write: exportAsTypesGo},
+	"types-python":   {write: exportAsTypesPython},
+	"types-zod":      {write: exportAsTypesZod},
+	"template":       {write: exportAsTemplate, structured: true},
+}
+
+func paramsOnly(f func(params map[string]string, w io.Writer) error) func(exportInput, io.Writer) error {
+	return func(in exportInput, w io.Writer) error {
+		return f(in.params, w)
+	}
+}
+
+func document(f func(doc interface{}, w io.Writer) error) func(exportInput, io.Writer) error {
+	return func(in exportInput, w io.Writer) error {
+		return f(in.data(), w)
+	}
+}
+
+// exportFormats lists formats usable with the export command. template is
+// excluded as it requires a template file, see render command.
+func exportFormats() []string {
+	var formats []string
+	for f := range exporters {
+		if f != "template" {
+			formats = append(formats, f)
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func envKey(k string) string {
+	return strings.Replace(strings.ToUpper(k), "-", "_", -1)
+}
+
+func exportAsShell(params map[string]string, w io.Writer) error {
+	for _, k := range sortedKeys(params) {
+		value := strings.Replace(params[k], `'`, `'\''`, -1)
+		fmt.Fprintf(w, "export %s='%s'\n", envKey(k), value)
+	}
+	return nil
+}
+
+func exportAsFish(params map[string]string, w io.Writer) error {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	for _, k := range sortedKeys(params) {
+		fmt.Fprintf(w, "set -gx %s '%s'\n", envKey(k), r.Replace(params[k]))
+	}
+	return nil
+}
+
+func exportAsDockerEnv(params map[string]string, w io.Writer) error {
+	for _, k := range sortedKeys(params) {
+		if strings.ContainsAny(params[k], "\r\n") {
+			return errors.Errorf("docker-env does not support multiline values, key = %s", k)
+		}
+		fmt.Fprintf(w, "%s=%s\n", envKey(k), params[k])
+	}
+	return nil
+}
+
+func exportAsSystemd(params map[string]string, w io.Writer) error {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+	for _, k := range sortedKeys(params) {
+		fmt.Fprintf(w, "%s=\"%s\"\n", envKey(k), r.Replace(params[k]))
+	}
+	return nil
+}
+
+func exportAsGithubActions(params map[string]string, w io.Writer) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(b)
+
+	for _, k := range sortedKeys(params) {
+		if strings.Contains(params[k], delimiter) {
+			return errors.Errorf("value of %s contains the delimiter", k)
+		}
+		fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", envKey(k), delimiter, params[k], delimiter)
+	}
+	return nil
+}
+
+func exportAsProperties(params map[string]string, w io.Writer) error {
+	for _, k := range sortedKeys(params) {
+		fmt.Fprintf(w, "%s=%s\n", propertiesEscape(k, true), propertiesEscape(params[k], false))
+	}
+	return nil
+}
+
+func propertiesEscape(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			if key || i == 0 {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		case r == ' ':
+			if key || i == 0 {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var bareTomlKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func exportAsToml(params map[string]string, w io.Writer) error {
+	for _, k := range sortedKeys(params) {
+		key := k
+		if !bareTomlKey.MatchString(k) {
+			key = tomlQuote(k)
+		}
+		fmt.Fprintf(w, "%s = %s\n", key, tomlQuote(params[k]))
+	}
+	return nil
+}
+
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteRune('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteRune('"')
+	return b.String()
+}
+
+// iniEscaper escapes quoted ini values. Unlike shell, $ and ! are literal.
+// Line breaks are escaped to keep a value on one line.
+var iniEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+func exportAsIni(params map[string]string, w io.Writer) error {
+	for _, k := range sortedKeys(params) {
+		value := params[k]
+		if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\"'\\;#=\r\n") {
+			value = fmt.Sprintf(`"%s"`, iniEscaper.Replace(value))
+		}
+		fmt.Fprintf(w, "%s = %s\n", k, value)
+	}
+	return nil
+}
+
+func exportAsTfvars(params map[string]string, w io.Writer) error {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{")
+	for _, k := range sortedKeys(params) {
+		if !bareTomlKey.MatchString(k) {
+			return errors.Errorf("%s is not a valid terraform variable name", k)
+		}
+		fmt.Fprintf(w, "%s = \"%s\"\n", k, r.Replace(params[k]))
+	}
+	return nil
+}
+
+type k8sManifest struct {
+	ApiVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+func exportAsK8sSecret(in exportInput, w io.Writer) error {
+	data := map[string]string{}
+	for k, v := range in.params {
+		data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+
+	return yaml.NewEncoder(w).Encode(k8sManifest{
+		ApiVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   k8sMeta(in.config),
+		Type:       "Opaque",
+		Data:       data,
+	})
+}
+
+func exportAsK8sConfigMap(in exportInput, w io.Writer) error {
+	return yaml.NewEncoder(w).Encode(k8sManifest{
+		ApiVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   k8sMeta(in.config),
+		Data:       in.params,
+	})
+}
+
+var invalidK8sNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func k8sMeta(config *c.Config) k8sMetadata {
+	name := config.Service
+	if config.Stage != "" {
+		name = fmt.Sprintf("%s-%s", config.Service, config.Stage)
+	}
+
+	name = invalidK8sNameChars.ReplaceAllString(strings.ToLower(name), "-")
+
+	return k8sMetadata{
+		Name:   strings.Trim(name, "-"),
+		Labels: map[string]string{"app.kubernetes.io/managed-by": "safebox"},
+	}
+}
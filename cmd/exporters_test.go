@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+)
+
+func TestExporters(t *testing.T) {
+	tests := []struct {
+		format  string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{format: "dotenv", params: map[string]string{"db-name": `a "b" $c`}, want: "DB_NAME=\"a \\\"b\\\" \\$c\"\n"},
+		{format: "shell", params: map[string]string{"KEY": "it's $HOME"}, want: "export KEY='it'\\''s $HOME'\n"},
+		{format: "fish", params: map[string]string{"KEY": `it's \`}, want: "set -gx KEY 'it\\'s \\\\'\n"},
+		{format: "properties", params: map[string]string{"a key": "=x\ny"}, want: "a\\ key=\\=x\\ny\n"},
+		{format: "toml", params: map[string]string{"KEY": "a\"b", "a.b": "c"}, want: "KEY = \"a\\\"b\"\n\"a.b\" = \"c\"\n"},
+		{format: "ini", params: map[string]string{"PLAIN": "value", "PRICE": "$5 = cheap!", "LINES": "a\nb"}, want: "LINES = \"a\\nb\"\nPLAIN = value\nPRICE = \"$5 = cheap!\"\n"},
+		{format: "ini", params: map[string]string{"QUOTED": `say "hi" \o/`}, want: "QUOTED = \"say \\\"hi\\\" \\\\o/\"\n"},
+		{format: "tfvars", params: map[string]string{"key": "${var}"}, want: "key = \"$${var}\"\n"},
+		{format: "tfvars", params: map[string]string{"a.b": "c"}, wantErr: true},
+		{format: "docker-env", params: map[string]string{"key": "a b"}, want: "KEY=a b\n"},
+		{format: "docker-env", params: map[string]string{"key": "a\nb"}, wantErr: true},
+		{format: "systemd", params: map[string]string{"KEY": "$a `b`"}, want: "KEY=\"\\$a \\`b\\`\"\n"},
+		{format: "json", params: map[string]string{"KEY": "a"}, want: "{\n  \"KEY\": \"a\"\n}"},
+		{format: "yaml", params: map[string]string{"KEY": "a"}, want: "KEY: a\n"},
+		{format: "k8s-secret", params: map[string]string{"KEY": "a b"}, want: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: my-api-dev\n  labels:\n    app.kubernetes.io/managed-by: safebox\ntype: Opaque\ndata:\n  KEY: YSBi\n"},
+		{format: "k8s-configmap", params: map[string]string{"KEY": "a b"}, want: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: my-api-dev\n  labels:\n    app.kubernetes.io/managed-by: safebox\ndata:\n  KEY: a b\n"},
+	}
+
+	config := &c.Config{Service: "My_Api", Stage: "dev"}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+
+			err := exporters[tt.format].write(exportInput{config: config, params: tt.params}, &b)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && b.String() != tt.want {
+				t.Errorf("write() = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
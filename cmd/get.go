@@ -25,31 +25,43 @@ func init() {
 	rootCmd.AddCommand(getCmd)
 }
 
-func getE(_ *cobra.Command, _ []string) error {
-	config, err := loadConfig()
+func getE(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to load config")
 	}
 
-	st, err := store.GetStore(store.StoreConfig{
+	st, err := store.GetStore(ctx, store.StoreConfig{
 		Provider: config.Provider,
 		Region:   config.Region,
 		FilePath: config.Filepath,
+		Session:  config.Session,
 	})
 
 	if err != nil {
 		return errors.Wrap(err, "failed to instantiate store")
 	}
 
-	found, err := st.Get(store.ConfigInput{Name: fmt.Sprintf("%s%s", config.Prefix, getParam)})
+	found, err := st.Get(ctx, store.ConfigInput{Name: fmt.Sprintf("%s%s", config.Prefix, getParam)})
+
+	if errors.Cause(err) == store.ConfigNotFoundError {
+		found, err = nil, nil
+	}
 
 	if err != nil {
 		return errors.Wrap(err, "failed to get param")
 	}
 
+	var data interface{}
 	if found != nil {
-		fmt.Printf("%s\n", *found.Value)
+		data = paramDoc(*found)
 	}
-	return nil
+
+	return printResult("get", config, data, func() {
+		if found != nil {
+			fmt.Printf("%s\n", *found.Value)
+		}
+	})
 }
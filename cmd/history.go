@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyReveal bool
+
+	historyCmd = &cobra.Command{
+		Use:   "history <key>",
+		Short: "Lists previous versions of a parameter",
+		Long: `Lists previous versions of a parameter, newest first. The key is looked up
+in the config file, preferring keys of the service over shared keys. Any
+other key is read from the prefix, or pass the full name of the parameter.
+
+Secrets are masked unless --reveal is passed. Version history is available
+for ssm and secrets-manager providers.`,
+		Example: `  safebox history DB_NAME
+  safebox history --reveal API_KEY --output json`,
+		Args: cobra.ExactArgs(1),
+		RunE: history,
+	}
+)
+
+type HistoryDoc struct {
+	Name     string              `json:"name" yaml:"name"`
+	Secret   bool                `json:"secret" yaml:"secret"`
+	Versions []HistoryVersionDoc `json:"versions" yaml:"versions"`
+}
+
+type HistoryVersionDoc struct {
+	ParamDoc `yaml:",inline"`
+	Stages   []string `json:"stages,omitempty" yaml:"stages,omitempty"`
+}
+
+func init() {
+	historyCmd.Flags().BoolVar(&historyReveal, "reveal", false, "show values of secrets")
+	historyCmd.Flags().BoolVarP(&revealYes, "yes", "y", false, "do not ask for confirmation when revealing secrets")
+
+	rootCmd.AddCommand(historyCmd)
+}
+
+func history(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate store")
+	}
+
+	h, ok := st.(store.HistoryReader)
+
+	if !ok {
+		return errors.Errorf("version history is not available for %s provider", config.Provider)
+	}
+
+	if historyReveal {
+		if err := confirmReveal(); err != nil {
+			return err
+		}
+	}
+
+	name, secret := historyTarget(config, args[0])
+	versions, err := h.GetHistory(ctx, name)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read history")
+	}
+
+	result := HistoryDoc{Name: name, Versions: []HistoryVersionDoc{}}
+
+	for _, v := range versions {
+		result.Secret = secret || v.Type == "SecureString"
+
+		doc := HistoryVersionDoc{ParamDoc: paramDoc(v), Stages: v.VersionStages}
+		doc.Secret = result.Secret
+
+		if doc.Secret && !historyReveal {
+			doc.Value = maskValue(doc.Value)
+			doc.Masked = true
+		}
+
+		result.Versions = append(result.Versions, doc)
+	}
+
+	return printResult("history", config, result, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+
+		fmt.Fprintln(w, "Version\tLastModified\tStages\tValue")
+
+		for _, v := range result.Versions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				v.Version,
+				v.Modified.Local().Format(TimeFormat),
+				strings.Join(v.Stages, ", "),
+				oneLine(v.Value),
+			)
+		}
+		fmt.Fprintln(w, "---")
+		w.Flush()
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("%s versions = %d", name, len(result.Versions)),
+			Config:  *config,
+		})
+	})
+}
+
+// historyTarget resolves key to the name of a parameter. Keys of the service
+// take precedence over shared keys, as in exports.
+func historyTarget(config *c.Config, key string) (string, bool) {
+	if strings.HasPrefix(key, "/") {
+		for _, d := range config.All {
+			if d.Name == key {
+				return d.Name, d.Secret
+			}
+		}
+		return key, false
+	}
+
+	var found *store.ConfigInput
+
+	for i, d := range config.All {
+		if d.Key() == key && (found == nil || found.Shared) {
+			found = &config.All[i]
+		}
+	}
+
+	if found != nil {
+		return found.Name, found.Secret
+	}
+
+	return config.Prefix + key, false
+}
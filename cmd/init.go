@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adikari/safebox/v2/aws"
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/adikari/safebox/v2/util"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const schemaHeader = "# yaml-language-server: $schema=https://raw.githubusercontent.com/adikari/safebox/main/schema.json\n"
+
+var (
+	initService  string
+	initProvider string
+	initRegion   string
+	initStages   []string
+	fromPath     string
+	initForce    bool
+
+	initCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Creates a safebox configuration file",
+		Long: `Creates a safebox configuration file, asking for service, provider,
+region and stages that are not passed as flags.
+
+With --from-path the file is created from parameters already deployed under
+the path. String parameters are added to config with their values and
+SecureString parameters to secret with their descriptions.`,
+		Example: `  safebox init
+  safebox init --service my-service --provider ssm --stages dev,prod
+  safebox init --from-path /prod/my-service/`,
+		RunE: initE,
+	}
+)
+
+func init() {
+	initCmd.Flags().StringVar(&initService, "service", "", "name of the service")
+	initCmd.Flags().StringVar(&initProvider, "provider", "", "provider (ssm, secrets-manager, gpg)")
+	initCmd.Flags().StringVar(&initRegion, "region", "", "aws region")
+	initCmd.Flags().StringSliceVar(&initStages, "stages", []string{}, "stages of the service, eg. dev,prod")
+	initCmd.Flags().StringVar(&fromPath, "from-path", "", "create config from parameters under the path, eg. /prod/my-service/")
+	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "overwrite existing config file")
+
+	rootCmd.AddCommand(initCmd)
+}
+
+func initE(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	path := c.ResolvePath(pathToConfig)
+
+	if _, err := os.Stat(path); err == nil && !initForce {
+		return errors.Errorf("%s already exists. run with --force to overwrite", path)
+	}
+
+	service, pathStage, prefix := fromPathParts(fromPath)
+
+	if len(initStages) == 0 && pathStage != "" {
+		initStages = []string{pathStage}
+	}
+
+	interactive := isTerminal(os.Stdin)
+
+	if initService == "" {
+		initService = service
+	}
+
+	if initService == "" {
+		dir, _ := os.Getwd()
+		initService = filepath.Base(dir)
+
+		if interactive {
+			p := promptui.Prompt{Label: "Service", Default: initService, Stdout: os.Stderr}
+			result, err := p.Run()
+			if err != nil {
+				return errors.Wrap(err, "aborted")
+			}
+			initService = result
+		}
+	}
+
+	if initProvider == "" {
+		initProvider = util.SsmProvider
+
+		if interactive {
+			providers := []string{util.SsmProvider, util.SecretsManagerProvider, util.GpgProvider}
+			s := promptui.Select{Label: "Provider", Items: providers, Stdout: os.Stderr}
+			_, result, err := s.Run()
+			if err != nil {
+				return errors.Wrap(err, "aborted")
+			}
+			initProvider = result
+		}
+	}
+
+	if fromPath != "" && !util.IsAwsProvider(initProvider) {
+		return errors.New("--from-path requires ssm or secrets-manager provider")
+	}
+
+	if initRegion == "" && util.IsAwsProvider(initProvider) && interactive {
+		p := promptui.Prompt{Label: "Region (empty for aws default)", Default: os.Getenv("AWS_REGION"), Stdout: os.Stderr}
+		result, err := p.Run()
+		if err != nil {
+			return errors.Wrap(err, "aborted")
+		}
+		initRegion = result
+	}
+
+	if len(initStages) == 0 && fromPath == "" && interactive {
+		p := promptui.Prompt{Label: "Stages (comma separated)", Stdout: os.Stderr}
+		result, err := p.Run()
+		if err != nil {
+			return errors.Wrap(err, "aborted")
+		}
+		for _, s := range strings.Split(result, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				initStages = append(initStages, s)
+			}
+		}
+	}
+
+	doc := yaml.MapSlice{
+		{Key: "service", Value: initService},
+		{Key: "provider", Value: initProvider},
+	}
+
+	if initRegion != "" {
+		doc = append(doc, yaml.MapItem{Key: "region", Value: initRegion})
+	}
+
+	// paths other than /<stage>/<service>/ are kept as prefix
+	if prefix != "" {
+		doc = append(doc, yaml.MapItem{Key: "prefix", Value: prefix})
+	}
+
+	if len(initStages) > 0 {
+		stages := yaml.MapSlice{}
+		for _, s := range initStages {
+			stages = append(stages, yaml.MapItem{Key: s, Value: map[string]string{}})
+		}
+		doc = append(doc, yaml.MapItem{Key: "stages", Value: stages})
+	}
+
+	imported := 0
+
+	if fromPath != "" {
+		configs, secrets, err := paramsFromPath(ctx, fromPath)
+
+		if err != nil {
+			return err
+		}
+
+		if len(configs) > 0 {
+			doc = append(doc, yaml.MapItem{Key: "config", Value: yaml.MapSlice{{Key: "defaults", Value: configs}}})
+		}
+
+		if len(secrets) > 0 {
+			doc = append(doc, yaml.MapItem{Key: "secret", Value: yaml.MapSlice{{Key: "defaults", Value: secrets}}})
+		}
+
+		imported = len(configs) + len(secrets)
+	}
+
+	b, err := yaml.Marshal(doc)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create config")
+	}
+
+	if err := ioutil.WriteFile(path, append([]byte(schemaHeader), b...), 0644); err != nil {
+		return errors.Wrap(err, "failed to write config")
+	}
+
+	fmt.Printf("wrote file -> %s\n", path)
+
+	if fromPath != "" {
+		fmt.Printf("imported parameters = %d\n", imported)
+	}
+
+	return nil
+}
+
+// fromPathParts splits /<stage>/<service>/ into service and stage. Any other
+// path is returned as prefix with its last segment as service.
+func fromPathParts(path string) (service string, stage string, prefix string) {
+	if path == "" {
+		return "", "", ""
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	service = parts[len(parts)-1]
+
+	switch len(parts) {
+	case 1:
+		return service, "", ""
+	case 2:
+		return service, parts[0], ""
+	}
+
+	return service, "", "/" + strings.Join(parts, "/") + "/"
+}
+
+// paramsFromPath reads parameters under path. Values of configs and
+// descriptions of secrets are returned by key, as declared in config file.
+func paramsFromPath(ctx context.Context, path string) (yaml.MapSlice, yaml.MapSlice, error) {
+	if !strings.HasSuffix(path, "/") {
+		path = path + "/"
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: initProvider,
+		Region:   initRegion,
+		Session: aws.SessionConfig{
+			Profile:     awsAuth.Profile,
+			RoleArn:     awsAuth.RoleArn,
+			ExternalId:  awsAuth.ExternalId,
+			MfaSerial:   awsAuth.MfaSerial,
+			EndpointUrl: endpointUrl,
+		},
+	})
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to instantiate store")
+	}
+
+	params, err := st.GetByPath(ctx, path)
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list params by path")
+	}
+
+	if r, ok := st.(store.DescriptionReader); ok {
+		descriptions, err := r.GetDescriptions(ctx, path)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range params {
+			params[i].Description = descriptions[*params[i].Name]
+		}
+	}
+
+	configs := yaml.MapSlice{}
+	secrets := yaml.MapSlice{}
+
+	sort.Slice(params, func(i, j int) bool { return *params[i].Name < *params[j].Name })
+
+	for _, p := range params {
+		key := strings.TrimPrefix(*p.Name, path)
+
+		if strings.Contains(key, "/") {
+			fmt.Fprintf(os.Stderr, "Warning: skipping nested parameter %s\n", *p.Name)
+			continue
+		}
+
+		if p.Type == "SecureString" {
+			secrets = append(secrets, yaml.MapItem{Key: key, Value: p.Description})
+			continue
+		}
+
+		configs = append(configs, yaml.MapItem{Key: key, Value: escapeTemplate(*p.Value)})
+	}
+
+	return configs, secrets, nil
+}
+
+// escapeTemplate keeps values containing {{ from being interpolated
+func escapeTemplate(value string) string {
+	return strings.ReplaceAll(value, "{{", `{{"{{"}}`)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import "testing"
+
+func TestFromPathParts(t *testing.T) {
+	tests := []struct {
+		path    string
+		service string
+		stage   string
+		prefix  string
+	}{
+		{path: "", service: "", stage: "", prefix: ""},
+		{path: "svc", service: "svc", stage: "", prefix: ""},
+		{path: "/svc/", service: "svc", stage: "", prefix: ""},
+		{path: "/prod/svc/", service: "svc", stage: "prod", prefix: ""},
+		{path: "prod/svc", service: "svc", stage: "prod", prefix: ""},
+		{path: "/a/b/c/", service: "c", stage: "", prefix: "/a/b/c/"},
+		{path: "/a/b/c", service: "c", stage: "", prefix: "/a/b/c/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			service, stage, prefix := fromPathParts(tt.path)
+
+			if service != tt.service || stage != tt.stage || prefix != tt.prefix {
+				t.Errorf("fromPathParts(%q) = (%q, %q, %q), want (%q, %q, %q)", tt.path, service, stage, prefix, tt.service, tt.stage, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestEscapeTemplate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "{{ .stage }}", want: `{{"{{"}} .stage }}`},
+		{value: "a{{b}}c{{d}}", want: `a{{"{{"}}b}}c{{"{{"}}d}}`},
+		{value: "}} {", want: "}} {"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := escapeTemplate(tt.value); got != tt.want {
+				t.Errorf("escapeTemplate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
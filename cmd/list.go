@@ -16,59 +16,207 @@ import (
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Lists all the configs available",
-	RunE:  list,
+	Long: `Lists all declared configs and any other parameters under the prefix.
+
+Each parameter is marked with its status:
+  present      deployed and up to date
+  missing      declared in config file but not deployed
+  orphan       deployed under the prefix but not declared
+  out-of-date  deployed value differs from config file`,
+	RunE: list,
 }
 
+const (
+	StatusPresent   = "present"
+	StatusMissing   = "missing"
+	StatusOrphan    = "orphan"
+	StatusOutOfDate = "out-of-date"
+)
+
 var (
 	sortByModified bool
 	sortByVersion  bool
+	reveal         bool
+	revealKeys     []string
+	revealYes      bool
 )
 
 func init() {
 	listCmd.Flags().BoolVarP(&sortByModified, "modified", "m", false, "sort by modified time")
 	listCmd.Flags().BoolVarP(&sortByVersion, "version", "v", false, "sort by version")
+	listCmd.Flags().BoolVar(&reveal, "reveal", false, "show values of all secrets")
+	listCmd.Flags().StringSliceVar(&revealKeys, "reveal-key", []string{}, "show value of given secret")
+	listCmd.Flags().BoolVarP(&revealYes, "yes", "y", false, "do not ask for confirmation when revealing secrets")
 
 	rootCmd.AddCommand(listCmd)
 }
 
-func list(_ *cobra.Command, _ []string) error {
-	config, err := loadConfig()
+func list(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to load config")
 	}
 
-	store, err := store.GetStore(store.StoreConfig{
+	store, err := store.GetStore(ctx, store.StoreConfig{
 		Provider: config.Provider,
 		Region:   config.Region,
 		FilePath: config.Filepath,
+		Session:  config.Session,
 	})
 
 	if err != nil {
 		return errors.Wrap(err, "failed to instantiate store")
 	}
 
-	configs, err := store.GetMany(config.All)
+	if reveal || len(revealKeys) > 0 {
+		if err := confirmReveal(); err != nil {
+			return err
+		}
+	}
+
+	configs, err := store.GetMany(ctx, config.All)
 
 	if err != nil {
 		return errors.Wrap(err, "failed to list params")
 	}
 
+	existing, err := store.GetByPath(ctx, config.Prefix)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to list params by path")
+	}
+
+	items := listItems(configs, existing, config)
+
 	if sortByVersion {
-		sort.Sort(ByVersion(configs))
+		sort.Sort(ByVersion(items))
 	} else if sortByModified {
-		sort.Sort(ByModified(configs))
+		sort.Sort(ByModified(items))
 	} else {
-		sort.Sort(ByName(configs))
+		sort.Sort(ByName(items))
+	}
+
+	return printList(items, config)
+}
+
+// confirmReveal asks before printing secrets anywhere but a terminal, eg. CI logs
+func confirmReveal() error {
+	if isTerminal(os.Stdout) {
+		return nil
+	}
+
+	ok, err := confirm("Output is not a terminal. Reveal secrets", revealYes)
+
+	if err != nil {
+		return err
 	}
 
-	printList(configs, config)
+	if !ok {
+		return errors.New("aborted")
+	}
 
 	return nil
 }
 
-func printList(configs []store.Config, cfg *config.Config) {
-	if len(configs) <= 0 {
+// listItems merges declared configs with the store. configs are the stored
+// values of declared keys and existing is everything stored under the prefix.
+func listItems(configs []store.Config, existing []store.Config, cfg *config.Config) []ParamDoc {
+	items := []ParamDoc{}
+	seen := map[string]bool{}
+
+	for _, d := range cfg.All {
+		if seen[d.Name] {
+			continue
+		}
+		seen[d.Name] = true
+
+		var found *store.Config
+		for i := range configs {
+			if *configs[i].Name == d.Name {
+				found = &configs[i]
+				break
+			}
+		}
+
+		if found == nil {
+			items = append(items, ParamDoc{
+				Name:        d.Name,
+				Key:         d.Key(),
+				Status:      StatusMissing,
+				Secret:      d.Secret,
+				Shared:      d.Shared,
+				Description: d.Description,
+			})
+			continue
+		}
+
+		item := listItem(*found, cfg)
+		item.Status = StatusPresent
+		if !d.Secret && d.Value != *found.Value {
+			item.Status = StatusOutOfDate
+		}
+		items = append(items, item)
+	}
+
+	for _, e := range existing {
+		if seen[*e.Name] {
+			continue
+		}
+		seen[*e.Name] = true
+
+		item := listItem(e, cfg)
+		item.Status = StatusOrphan
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func printList(items []ParamDoc, cfg *config.Config) error {
+	return printResult("list", cfg, items, func() {
+		printTable(items, cfg)
+	})
+}
+
+func listItem(c store.Config, cfg *config.Config) ParamDoc {
+	item := paramDoc(c)
+	item.Secret = c.Type == "SecureString"
+
+	for _, d := range cfg.All {
+		if d.Name == *c.Name {
+			item.Secret = d.Secret
+			item.Shared = d.Shared
+			item.Description = d.Description
+			break
+		}
+	}
+
+	if item.Secret && !shouldReveal(item.Key) {
+		item.Value = maskValue(item.Value)
+		item.Masked = true
+	}
+
+	return item
+}
+
+func shouldReveal(key string) bool {
+	if reveal {
+		return true
+	}
+
+	for _, k := range revealKeys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printTable(items []ParamDoc, cfg *config.Config) {
+	if len(items) <= 0 {
 
 		PrintSummary(Summary{
 			Message: "Total parameters = 0",
@@ -80,16 +228,33 @@ func printList(configs []store.Config, cfg *config.Config) {
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
 
-	fmt.Fprint(w, "Name\tValue\tType\tVersion\tLastModified")
+	fmt.Fprint(w, "Name\tStatus\tValue\tKind\tScope\tType\tVersion\tLastModified\tDescription")
 	fmt.Fprintln(w, "")
 
-	for _, config := range configs {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s",
-			*config.Name,
-			*config.Value,
-			config.Type,
-			config.Version,
-			config.Modified.Local().Format(TimeFormat),
+	for _, item := range items {
+		kind, scope := "config", "service"
+		if item.Secret {
+			kind = "secret"
+		}
+		if item.Shared {
+			scope = "shared"
+		}
+
+		modified := ""
+		if !item.Modified.IsZero() {
+			modified = item.Modified.Local().Format(TimeFormat)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+			item.Name,
+			item.Status,
+			item.Value,
+			kind,
+			scope,
+			item.Type,
+			item.Version,
+			modified,
+			item.Description,
 		)
 
 		fmt.Fprintln(w, "")
@@ -97,26 +262,26 @@ func printList(configs []store.Config, cfg *config.Config) {
 	fmt.Fprintln(w, "---")
 
 	PrintSummary(Summary{
-		Message: fmt.Sprintf("Total parameters = %d", len(configs)),
+		Message: fmt.Sprintf("Total parameters = %d", len(items)),
 		Config:  *cfg,
 	})
 
 	w.Flush()
 }
 
-type ByName []store.Config
+type ByName []ParamDoc
 
 func (a ByName) Len() int           { return len(a) }
 func (a ByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByName) Less(i, j int) bool { return *a[i].Name < *a[j].Name }
+func (a ByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
 
-type ByVersion []store.Config
+type ByVersion []ParamDoc
 
 func (a ByVersion) Len() int           { return len(a) }
 func (a ByVersion) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByVersion) Less(i, j int) bool { return a[i].Version < a[j].Version }
 
-type ByModified []store.Config
+type ByModified []ParamDoc
 
 func (a ByModified) Len() int           { return len(a) }
 func (a ByModified) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
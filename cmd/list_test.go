@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+)
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", "**** (0 chars)"},
+		{"short", "**** (5 chars)"},
+		{"a-much-longer-secret", "****cret (20 chars)"},
+		{"ключ-ключ-ключ-ключ", "****ключ (19 chars)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := maskValue(tt.value); got != tt.want {
+				t.Errorf("maskValue(%s) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListItem(t *testing.T) {
+	defer func(r bool, keys []string) { reveal, revealKeys = r, keys }(reveal, revealKeys)
+
+	cfg := &c.Config{
+		All: []store.ConfigInput{
+			{Name: "/dev/api/API_KEY", Secret: true, Description: "key of the api"},
+			{Name: "/dev/shared/REGION", Shared: true},
+		},
+	}
+
+	secure := param("/dev/api/TOKEN", "secure")
+	secure.Type = "SecureString"
+
+	tests := []struct {
+		name       string
+		param      store.Config
+		reveal     bool
+		revealKeys []string
+		want       ParamDoc
+	}{
+		{
+			name:  "secret is masked",
+			param: param("/dev/api/API_KEY", "secret"),
+			want:  ParamDoc{Key: "API_KEY", Value: "**** (6 chars)", Masked: true, Secret: true, Description: "key of the api"},
+		},
+		{
+			name:   "secret is revealed",
+			param:  param("/dev/api/API_KEY", "secret"),
+			reveal: true,
+			want:   ParamDoc{Key: "API_KEY", Value: "secret", Secret: true, Description: "key of the api"},
+		},
+		{
+			name:       "secret is revealed by key",
+			param:      param("/dev/api/API_KEY", "secret"),
+			revealKeys: []string{"API_KEY"},
+			want:       ParamDoc{Key: "API_KEY", Value: "secret", Secret: true, Description: "key of the api"},
+		},
+		{
+			name:       "other keys are not revealed",
+			param:      param("/dev/api/API_KEY", "secret"),
+			revealKeys: []string{"REGION"},
+			want:       ParamDoc{Key: "API_KEY", Value: "**** (6 chars)", Masked: true, Secret: true, Description: "key of the api"},
+		},
+		{
+			name:  "undeclared secure string is masked",
+			param: secure,
+			want:  ParamDoc{Key: "TOKEN", Value: "**** (6 chars)", Masked: true, Secret: true, Type: "SecureString"},
+		},
+		{
+			name:  "shared config",
+			param: param("/dev/shared/REGION", "us-east-1"),
+			want:  ParamDoc{Key: "REGION", Value: "us-east-1", Shared: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reveal, revealKeys = tt.reveal, tt.revealKeys
+
+			got := listItem(tt.param, cfg)
+			tt.want.Name = *tt.param.Name
+
+			if got != tt.want {
+				t.Errorf("listItem() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListItems(t *testing.T) {
+	cfg := &c.Config{
+		All: []store.ConfigInput{
+			{Name: "/dev/api/HOST", Value: "localhost"},
+			{Name: "/dev/api/PORT", Value: "80"},
+			{Name: "/dev/api/API_KEY", Secret: true},
+			{Name: "/dev/api/DB_PASSWORD", Secret: true},
+			{Name: "/dev/api/HOST", Value: "localhost"},
+		},
+	}
+
+	configs := []store.Config{
+		param("/dev/api/HOST", "localhost"),
+		param("/dev/api/PORT", "8080"),
+		param("/dev/api/API_KEY", "changed out of band"),
+	}
+
+	existing := append(configs, param("/dev/api/OLD", "old"))
+
+	got := map[string]string{}
+	for _, item := range listItems(configs, existing, cfg) {
+		if _, ok := got[item.Name]; ok {
+			t.Errorf("%s is listed twice", item.Name)
+		}
+		got[item.Name] = item.Status
+	}
+
+	want := map[string]string{
+		"/dev/api/HOST":        StatusPresent,
+		"/dev/api/PORT":        StatusOutOfDate,
+		"/dev/api/API_KEY":     StatusPresent,
+		"/dev/api/DB_PASSWORD": StatusMissing,
+		"/dev/api/OLD":         StatusOrphan,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listItems() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adikari/safebox/v2/audit"
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+)
+
+const protectedTag = "safebox:protected"
+
+// findOrphans returns parameters under the prefix that are no longer declared.
+// Shared parameters are included when configured, but only those owned by
+// the service.
+func findOrphans(ctx context.Context, st store.Store, config *c.Config) ([]store.Config, error) {
+	params, err := st.GetByPath(ctx, config.Prefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Orphans.IncludeShared {
+		shared, err := st.GetByPath(ctx, config.SharedPrefix)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range shared {
+			tags, err := tagsOf(ctx, st, s)
+			if err != nil {
+				return nil, err
+			}
+
+			if ownerOf(tags) == config.Service {
+				params = append(params, s)
+			}
+		}
+	}
+
+	var orphans []store.Config
+
+	for _, param := range params {
+		exists := false
+
+		for _, d := range config.All {
+			if d.Name == *param.Name {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			orphans = append(orphans, param)
+		}
+	}
+
+	return orphans, nil
+}
+
+// doRemoveOrphans deletes orphans after confirmation, skipping protected keys
+func doRemoveOrphans(ctx context.Context, st store.Store, config *c.Config) ([]store.ConfigInput, error) {
+	found, err := findOrphans(ctx, st, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, err := deletable(ctx, st, config, found, deleteLimit(config))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orphans) == 0 {
+		return orphans, nil
+	}
+
+	fmt.Fprintln(os.Stderr, "orphans to remove:")
+	for _, o := range orphans {
+		fmt.Fprintf(os.Stderr, "  %s\n", o.Name)
+	}
+
+	ok, err := confirm(fmt.Sprintf("Remove %d orphans", len(orphans)), assumeYes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, errors.New("orphan removal cancelled")
+	}
+
+	if err = st.DeleteMany(ctx, orphans); err != nil {
+		return nil, err
+	}
+
+	recordAudit(ctx, st, config, audit.ActionRemoveOrphan, orphans)
+
+	return orphans, nil
+}
+
+// deletable returns params that may be deleted. Protected params are skipped,
+// shared params owned by another service are refused and deleting more than
+// limit params at once fails.
+func deletable(ctx context.Context, st store.Store, config *c.Config, params []store.Config, limit int) ([]store.ConfigInput, error) {
+	var inputs []store.ConfigInput
+
+	for _, p := range params {
+		protected, err := isProtected(ctx, st, p, config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if protected {
+			fmt.Fprintf(os.Stderr, "skipping protected %s\n", *p.Name)
+			continue
+		}
+
+		if strings.HasPrefix(*p.Name, config.SharedPrefix) {
+			tags, err := tagsOf(ctx, st, p)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if owner := ownerOf(tags); owner != "" && owner != config.Service {
+				return nil, errors.Errorf("refusing to remove %s, it is owned by %s", *p.Name, owner)
+			}
+		}
+
+		inputs = append(inputs, store.ConfigInput{Name: *p.Name, Options: config.Options})
+	}
+
+	if len(inputs) > limit {
+		return nil, errors.Errorf("refusing to remove %d params, more than max-delete of %d. check the prefix or raise --max-delete", len(inputs), limit)
+	}
+
+	return inputs, nil
+}
+
+// deleteLimit is max-delete of config file, unless --max-delete is passed
+func deleteLimit(config *c.Config) int {
+	if maxDelete > 0 {
+		return maxDelete
+	}
+
+	return config.Orphans.MaxDelete
+}
+
+func isProtected(ctx context.Context, st store.Store, param store.Config, config *c.Config) (bool, error) {
+	for _, p := range config.Orphans.Protected {
+		if p == param.Key() || p == *param.Name {
+			return true, nil
+		}
+	}
+
+	tags, err := tagsOf(ctx, st, param)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tags[protectedTag] == "true", nil
+}
+
+// tagsOf returns tags of param, reading them from the store when not listed
+func tagsOf(ctx context.Context, st store.Store, param store.Config) (map[string]string, error) {
+	if param.Tags != nil {
+		return param.Tags, nil
+	}
+
+	if r, ok := st.(store.TagReader); ok {
+		return r.GetTags(ctx, *param.Name)
+	}
+
+	return map[string]string{}, nil
+}
+
+// ownerOf returns the service that owns a parameter. Parameters deployed
+// before ownership was tracked fall back to the service tag.
+func ownerOf(tags map[string]string) string {
+	if owner, ok := tags[c.OwnerTag]; ok {
+		return owner
+	}
+	return tags["service"]
+}
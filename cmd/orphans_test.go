@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/adikari/safebox/v2/store/storetest"
+)
+
+func TestDeletable(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  []string
+		tags    map[string]map[string]string
+		orphans c.Orphans
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "orphans",
+			params:  []string{"/dev/api/A", "/dev/api/B"},
+			orphans: c.Orphans{MaxDelete: 10},
+			want:    []string{"/dev/api/A", "/dev/api/B"},
+		},
+		{
+			name:    "protected by key in config",
+			params:  []string{"/dev/api/A", "/dev/api/B"},
+			orphans: c.Orphans{MaxDelete: 10, Protected: []string{"A"}},
+			want:    []string{"/dev/api/B"},
+		},
+		{
+			name:    "protected by name in config",
+			params:  []string{"/dev/api/A", "/dev/api/B"},
+			orphans: c.Orphans{MaxDelete: 10, Protected: []string{"/dev/api/B"}},
+			want:    []string{"/dev/api/A"},
+		},
+		{
+			name:    "protected by tag",
+			params:  []string{"/dev/api/A", "/dev/api/B"},
+			tags:    map[string]map[string]string{"/dev/api/A": {protectedTag: "true"}},
+			orphans: c.Orphans{MaxDelete: 10},
+			want:    []string{"/dev/api/B"},
+		},
+		{
+			name:    "more than max delete",
+			params:  []string{"/dev/api/A", "/dev/api/B", "/dev/api/C"},
+			orphans: c.Orphans{MaxDelete: 2},
+			wantErr: "more than max-delete of 2",
+		},
+		{
+			name:    "protected params do not count to max delete",
+			params:  []string{"/dev/api/A", "/dev/api/B", "/dev/api/C"},
+			orphans: c.Orphans{MaxDelete: 2, Protected: []string{"C"}},
+			want:    []string{"/dev/api/A", "/dev/api/B"},
+		},
+		{
+			name:    "shared owned by the service",
+			params:  []string{"/dev/shared/A"},
+			tags:    map[string]map[string]string{"/dev/shared/A": {c.OwnerTag: "api"}},
+			orphans: c.Orphans{MaxDelete: 10},
+			want:    []string{"/dev/shared/A"},
+		},
+		{
+			name:    "shared owned by another service",
+			params:  []string{"/dev/shared/A"},
+			tags:    map[string]map[string]string{"/dev/shared/A": {c.OwnerTag: "billing", "service": "api"}},
+			orphans: c.Orphans{MaxDelete: 10},
+			wantErr: "owned by billing",
+		},
+		{
+			name:    "shared deployed by another service before ownership",
+			params:  []string{"/dev/shared/A"},
+			tags:    map[string]map[string]string{"/dev/shared/A": {"service": "billing"}},
+			orphans: c.Orphans{MaxDelete: 10},
+			wantErr: "owned by billing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := storetest.NewFakeSSM(tt.params...)
+			for name, tags := range tt.tags {
+				fake.Tags[name] = tags
+			}
+			st := store.NewSSMStoreWithClient(fake)
+
+			config := &c.Config{Service: "api", Prefix: "/dev/api/", SharedPrefix: "/dev/shared/", Orphans: tt.orphans}
+
+			var params []store.Config
+			for _, path := range []string{config.Prefix, config.SharedPrefix} {
+				found, err := st.GetByPath(ctx, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				params = append(params, found...)
+			}
+
+			got, err := deletable(ctx, st, config, params, deleteLimit(config))
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("deletable() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("deletable() error = %v", err)
+			}
+
+			names := []string{}
+			for _, i := range got {
+				names = append(names, i.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("deletable() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
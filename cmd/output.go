@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	TableOutput = "table"
+	JsonOutput  = "json"
+	YamlOutput  = "yaml"
+
+	// documentVersion is bumped on any breaking change to structured output
+	documentVersion = 1
+)
+
+var outputFormats = []string{TableOutput, JsonOutput, YamlOutput}
+
+// Document is the envelope of all structured output
+type Document struct {
+	Version int         `json:"version" yaml:"version"`
+	Kind    string      `json:"kind" yaml:"kind"`
+	Summary *SummaryDoc `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Data    interface{} `json:"data,omitempty" yaml:"data,omitempty"`
+	Error   string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type SummaryDoc struct {
+	Service  string `json:"service,omitempty" yaml:"service,omitempty"`
+	Stage    string `json:"stage,omitempty" yaml:"stage,omitempty"`
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Region   string `json:"region,omitempty" yaml:"region,omitempty"`
+	File     string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+type ParamDoc struct {
+	Name        string    `json:"name" yaml:"name"`
+	Key         string    `json:"key" yaml:"key"`
+	Status      string    `json:"status,omitempty" yaml:"status,omitempty"`
+	Value       string    `json:"value" yaml:"value"`
+	Masked      bool      `json:"masked,omitempty" yaml:"masked,omitempty"`
+	Secret      bool      `json:"secret" yaml:"secret"`
+	Shared      bool      `json:"shared" yaml:"shared"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Type        string    `json:"type" yaml:"type"`
+	Version     string    `json:"version" yaml:"version"`
+	Modified    time.Time `json:"modified" yaml:"modified"`
+}
+
+func isStructuredOutput() bool {
+	return outputFormat != TableOutput
+}
+
+func validateOutputFormat() error {
+	for _, f := range outputFormats {
+		if outputFormat == f {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid output format `%s`", outputFormat)
+}
+
+// printResult writes data as a structured document, or calls table when
+// output format is table
+func printResult(kind string, cfg *c.Config, data interface{}, table func()) error {
+	if !isStructuredOutput() {
+		table()
+		return nil
+	}
+
+	return writeDocument(os.Stdout, Document{
+		Version: documentVersion,
+		Kind:    kind,
+		Summary: summaryDoc(cfg),
+		Data:    data,
+	})
+}
+
+func printError(err error) {
+	if !isStructuredOutput() {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return
+	}
+
+	writeDocument(os.Stderr, Document{
+		Version: documentVersion,
+		Kind:    "error",
+		Error:   err.Error(),
+	})
+}
+
+func writeDocument(w io.Writer, doc Document) error {
+	if outputFormat == YamlOutput {
+		return yaml.NewEncoder(w).Encode(doc)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(doc)
+}
+
+func summaryDoc(cfg *c.Config) *SummaryDoc {
+	if cfg == nil {
+		return nil
+	}
+
+	s := &SummaryDoc{
+		Service:  cfg.Service,
+		Stage:    cfg.Stage,
+		Provider: cfg.Provider,
+		Region:   cfg.Region,
+	}
+
+	if cfg.Provider == "gpg" {
+		s.Region = ""
+		s.File = cfg.Filepath
+	}
+
+	return s
+}
+
+func paramDoc(config store.Config) ParamDoc {
+	return ParamDoc{
+		Name:     *config.Name,
+		Key:      config.Key(),
+		Value:    *config.Value,
+		Type:     config.Type,
+		Version:  config.Version,
+		Modified: config.Modified,
+	}
+}
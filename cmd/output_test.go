@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWriteDocument(t *testing.T) {
+	defer func(f string) { outputFormat = f }(outputFormat)
+
+	doc := Document{
+		Version: documentVersion,
+		Kind:    "diff",
+		Summary: summaryDoc(&c.Config{Service: "api", Stage: "dev", Provider: "gpg", Region: "us-east-1", Filepath: "secrets.json"}),
+		Data: DiffDoc{Changes: []DriftParamDoc{
+			{ParamDoc: ParamDoc{Name: "/dev/api/HOST", Key: "HOST", Status: StatusMissing}, Expected: "localhost"},
+		}},
+	}
+
+	for _, format := range []string{JsonOutput, YamlOutput} {
+		t.Run(format, func(t *testing.T) {
+			outputFormat = format
+
+			var buf bytes.Buffer
+			if err := writeDocument(&buf, doc); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]interface{}
+			if format == JsonOutput {
+				err := json.Unmarshal(buf.Bytes(), &got)
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				var m map[interface{}]interface{}
+				if err := yaml.Unmarshal(buf.Bytes(), &m); err != nil {
+					t.Fatal(err)
+				}
+				got = map[string]interface{}{}
+				for k, v := range m {
+					got[k.(string)] = v
+				}
+			}
+
+			if got["version"] != documentVersion && got["version"] != float64(documentVersion) {
+				t.Errorf("version = %v, want %d", got["version"], documentVersion)
+			}
+			if got["kind"] != "diff" {
+				t.Errorf("kind = %v, want diff", got["kind"])
+			}
+			if _, ok := got["error"]; ok {
+				t.Errorf("error is set on a successful document")
+			}
+
+			out := buf.String()
+			for _, want := range []string{"changes", "/dev/api/HOST", "secrets.json"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("%s output does not contain %s:\n%s", format, want, out)
+				}
+			}
+			// the region of gpg is not used
+			if strings.Contains(out, "us-east-1") {
+				t.Errorf("%s output contains the region of gpg:\n%s", format, out)
+			}
+		})
+	}
+}
+
+func TestHistoryTarget(t *testing.T) {
+	config := &c.Config{
+		Prefix: "/dev/api/",
+		All: []store.ConfigInput{
+			{Name: "/dev/shared/API_KEY", Secret: true, Shared: true},
+			{Name: "/dev/api/API_KEY", Secret: true},
+			{Name: "/dev/shared/REGION", Shared: true},
+			{Name: "/dev/api/HOST"},
+		},
+	}
+
+	tests := []struct {
+		key        string
+		wantName   string
+		wantSecret bool
+	}{
+		{"API_KEY", "/dev/api/API_KEY", true},
+		{"REGION", "/dev/shared/REGION", false},
+		{"HOST", "/dev/api/HOST", false},
+		{"UNDECLARED", "/dev/api/UNDECLARED", false},
+		{"/dev/shared/API_KEY", "/dev/shared/API_KEY", true},
+		{"/other/KEY", "/other/KEY", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			name, secret := historyTarget(config, tt.key)
+
+			if name != tt.wantName || secret != tt.wantSecret {
+				t.Errorf("historyTarget(%s) = %s, %v, want %s, %v", tt.key, name, secret, tt.wantName, tt.wantSecret)
+			}
+		})
+	}
+}
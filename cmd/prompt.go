@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// confirm asks a yes/no question on stderr. It fails when stdin is not
+// interactive, unless yes is set.
+func confirm(label string, yes bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("confirmation required. run with --yes to continue")
+	}
+
+	p := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+		Stdout:    os.Stderr,
+	}
+
+	if _, err := p.Run(); err != nil {
+		if err == promptui.ErrAbort {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// maskValue hides a secret, keeping its length and the last few characters
+// of long values so it can still be told apart
+func maskValue(value string) string {
+	r := []rune(value)
+
+	if len(r) < 16 {
+		return fmt.Sprintf("**** (%d chars)", len(r))
+	}
+
+	return fmt.Sprintf("****%s (%d chars)", string(r[len(r)-4:]), len(r))
+}
+
+// editValue opens value in $VISUAL or $EDITOR and returns the saved content.
+// A single trailing newline added by most editors is removed.
+func editValue(key string, value string) (string, error) {
+	b, err := editFile(fmt.Sprintf("safebox-%s-*", key), []byte(value))
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r"), nil
+}
+
+// editFile opens content in $VISUAL or $EDITOR from a temporary file only
+// readable by the current user. The file is wiped once the editor exits.
+func editFile(pattern string, content []byte) ([]byte, error) {
+	f, err := ioutil.TempFile(privateTempDir(), pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	path := f.Name()
+	defer wipeFile(path)
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := runEditor(path); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// privateTempDir prefers a memory backed directory, so secrets being edited
+// are never written to disk
+func privateTempDir() string {
+	if fi, err := os.Stat("/dev/shm"); err == nil && fi.IsDir() {
+		if f, err := ioutil.TempFile("/dev/shm", "safebox-*"); err == nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "/dev/shm"
+		}
+	}
+
+	return os.TempDir()
+}
+
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// wipeFile overwrites a file containing secrets before removing it
+func wipeFile(path string) error {
+	if fi, err := os.Stat(path); err == nil {
+		if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			f.Write(make([]byte, fi.Size()))
+			f.Sync()
+			f.Close()
+		}
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+)
+
+// fakeEditor sets $EDITOR to a script that keeps the edited file in seen and
+// replaces it with content
+func fakeEditor(t *testing.T, content string) string {
+	dir := t.TempDir()
+	seen := filepath.Join(dir, "seen")
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "content"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	script := "#!/bin/sh\ncp \"$1\" " + seen + "\necho \"$1\" >> " + seen + ".path\ncp " + filepath.Join(dir, "content") + " \"$1\"\n"
+	editor := filepath.Join(dir, "editor")
+
+	if err := ioutil.WriteFile(editor, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", editor)
+
+	return seen
+}
+
+func TestEditValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"trailing newline is removed", "new value\n", "new value"},
+		{"windows newline is removed", "new value\r\n", "new value"},
+		{"multiline value", "-----BEGIN KEY-----\nabc\n-----END KEY-----\n", "-----BEGIN KEY-----\nabc\n-----END KEY-----"},
+		{"only one newline is removed", "a\n\n", "a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := fakeEditor(t, tt.content)
+
+			got, err := editValue("API_KEY", "old value")
+
+			if err != nil {
+				t.Fatalf("editValue() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("editValue() = %q, want %q", got, tt.want)
+			}
+
+			if b, _ := ioutil.ReadFile(seen); string(b) != "old value" {
+				t.Errorf("editor opened %q, want the current value", string(b))
+			}
+
+			path, _ := ioutil.ReadFile(seen + ".path")
+			if _, err := os.Stat(strings.TrimSpace(string(path))); !os.IsNotExist(err) {
+				t.Errorf("edited file %s is not removed", path)
+			}
+		})
+	}
+}
+
+func TestWithValue(t *testing.T) {
+	input := store.ConfigInput{Name: "/dev/api/API_KEY", Value: "old", Secret: true}
+
+	got, err := withValue(input, func(key string, value string) (string, error) {
+		if key != "API_KEY" || value != "old" {
+			t.Errorf("read(%s, %s), want API_KEY and the current value", key, value)
+		}
+		return "new", nil
+	})
+
+	if err != nil || got.Value != "new" || !got.Secret {
+		t.Errorf("withValue() = %+v, %v, want the secret with the new value", got, err)
+	}
+
+	_, err = withValue(input, func(string, string) (string, error) { return "", nil })
+
+	if err == nil || !strings.Contains(err.Error(), "must not be empty") {
+		t.Errorf("withValue() error = %v, want empty values to fail", err)
+	}
+
+	_, err = withValue(input, func(string, string) (string, error) { return "", errors.New("no such file") })
+
+	if err == nil || err.Error() != "API_KEY: no such file" {
+		t.Errorf("withValue() error = %v, want the error of the key", err)
+	}
+}
+
+func TestConfirmWithoutTerminal(t *testing.T) {
+	if ok, err := confirm("Delete", true); !ok || err != nil {
+		t.Errorf("confirm() = %v, %v, want --yes to confirm", ok, err)
+	}
+
+	if isTerminal(os.Stdin) {
+		t.Skip("stdin is a terminal")
+	}
+
+	if ok, err := confirm("Delete", false); ok || err == nil {
+		t.Errorf("confirm() = %v, %v, want to fail without a terminal", ok, err)
+	}
+}
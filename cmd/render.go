@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templateFile string
+	renderOutput string
+	keysToRender []string
+	renderNested bool
+	renderJson   bool
+	renderSvc    bool
+
+	renderCmd = &cobra.Command{
+		Use:   "render",
+		Short: "Renders a go template file using configurations",
+		RunE:  render,
+	}
+)
+
+func init() {
+	renderCmd.Flags().StringVarP(&templateFile, "template", "t", "", "template file to render")
+	renderCmd.Flags().StringVarP(&renderOutput, "output-file", "o", "", "output file (default is standard output)")
+	renderCmd.Flags().StringSliceVarP(&keysToRender, "key", "k", []string{}, "only make specified config available to the template (default is all)")
+	renderCmd.Flags().BoolVar(&renderNested, "nested", false, "group keys under shared and service")
+	renderCmd.Flags().BoolVar(&renderJson, "expand-json", false, "make json values available as objects")
+	renderCmd.Flags().BoolVar(&renderSvc, "prefer-service", false, "use the service value of keys declared in both shared and service")
+	renderCmd.MarkFlagRequired("template")
+	renderCmd.MarkFlagFilename("template")
+	renderCmd.MarkFlagFilename("output-file")
+
+	rootCmd.AddCommand(renderCmd)
+}
+
+func render(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	return exportToFile(ctx, ExportParams{
+		config:       config,
+		keysToExport: keysToRender,
+		format:       "template",
+		output:       renderOutput,
+		template:     templateFile,
+		nested:       renderNested,
+		expandJson:   renderJson,
+		preferSvc:    renderSvc,
+	})
+}
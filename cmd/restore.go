@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adikari/safebox/v2/audit"
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	RestoreCreate    = "create"
+	RestoreUpdate    = "update"
+	RestoreUnchanged = "unchanged"
+)
+
+var (
+	restoreIdentities  []string
+	restoreDryRun      bool
+	restoreSkipOrphans bool
+	restoreYes         bool
+
+	restoreCmd = &cobra.Command{
+		Use:   "restore <archive>",
+		Short: "Restores parameters from a backup archive",
+		Long: `Restores parameters from a backup archive into the stage and provider of the
+config file, which may differ from the backup. Parameters under the prefix and
+shared prefix of the backup are moved to those of the target stage.
+
+A preview of the changes with current and restored values, secrets masked,
+is shown and confirmation asked before writing. Shared parameters owned by
+another service are checked as in deploy.
+Age archives are decrypted with --identity files, or a passphrase read from
+SAFEBOX_BACKUP_PASSPHRASE or asked for.`,
+		Args: cobra.ExactArgs(1),
+		RunE: restore,
+	}
+)
+
+type RestoreDoc struct {
+	Source  string           `json:"source" yaml:"source"`
+	DryRun  bool             `json:"dryRun" yaml:"dryRun"`
+	Changes []RestoreItemDoc `json:"changes" yaml:"changes"`
+}
+
+type RestoreItemDoc struct {
+	Name    string `json:"name" yaml:"name"`
+	From    string `json:"from" yaml:"from"`
+	Action  string `json:"action" yaml:"action"`
+	Secret  bool   `json:"secret" yaml:"secret"`
+	Current string `json:"current,omitempty" yaml:"current,omitempty"`
+	Value   string `json:"value" yaml:"value"`
+}
+
+func init() {
+	restoreCmd.Flags().StringSliceVar(&restoreIdentities, "identity", []string{}, "age identity file to decrypt with")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "only show changes")
+	restoreCmd.Flags().BoolVar(&restoreSkipOrphans, "skip-orphans", false, "do not restore parameters that were orphans at backup")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "restore without confirmation")
+	restoreCmd.MarkFlagFilename("identity")
+
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	data, err := ioutil.ReadFile(args[0])
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read backup")
+	}
+
+	data, err = decryptArchive(data, restoreIdentities)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to decrypt backup")
+	}
+
+	var archive Archive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return errors.Wrap(err, "failed to parse backup")
+	}
+
+	if archive.Version > archiveVersion {
+		return fmt.Errorf("backup version %d is not supported, upgrade safebox", archive.Version)
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate store")
+	}
+
+	result, toRestore, err := restoreChanges(ctx, st, config, archive)
+
+	if err != nil {
+		return err
+	}
+
+	result.Source = args[0]
+
+	if !restoreDryRun && len(toRestore) > 0 {
+		printRestoreChanges(os.Stderr, result.Changes)
+
+		ok, err := confirm(fmt.Sprintf("Restore %d params from %s/%s", len(toRestore), archive.Service, archive.Stage), restoreYes)
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errors.New("restore cancelled")
+		}
+
+		if err := st.PutMany(ctx, toRestore); err != nil {
+			return errors.Wrap(err, "failed to write params")
+		}
+
+		recordAudit(ctx, st, config, audit.ActionRestore, toRestore)
+	}
+
+	return printResult("restore", config, result, func() {
+		if restoreDryRun {
+			printRestoreChanges(os.Stdout, result.Changes)
+		}
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("restored configs = %d", len(toRestore)),
+			Config:  *config,
+		})
+	})
+}
+
+// restoreChanges compares params of the archive with the store and returns
+// the params to write. Shared params owned by another service keep their
+// owner and changing their value warns or fails, as in deploy.
+func restoreChanges(ctx context.Context, st store.Store, config *c.Config, archive Archive) (RestoreDoc, []store.ConfigInput, error) {
+	inputs, sources := restoreInputs(archive, config)
+	archived := map[string]ArchiveParam{}
+	for _, p := range archive.Params {
+		archived[p.Name] = p
+	}
+
+	current, err := st.GetMany(ctx, inputs)
+
+	if err != nil {
+		return RestoreDoc{}, nil, errors.Wrap(err, "failed to read existing params")
+	}
+
+	result := RestoreDoc{DryRun: restoreDryRun, Changes: []RestoreItemDoc{}}
+	toRestore := []store.ConfigInput{}
+
+	for i, in := range inputs {
+		item := RestoreItemDoc{Name: in.Name, From: sources[i], Action: RestoreCreate, Secret: in.Secret, Value: in.Value}
+
+		var currentTags map[string]string
+
+		if found := findConfig(in.Name, current); found != nil {
+			item.Action = RestoreUpdate
+			item.Current = *found.Value
+			if *found.Value == in.Value {
+				item.Action = RestoreUnchanged
+			}
+
+			if currentTags, err = tagsOf(ctx, st, *found); err != nil {
+				return RestoreDoc{}, nil, errors.Wrap(err, "failed to read tags of existing params")
+			}
+		}
+
+		if in.Secret {
+			item.Value = maskValue(item.Value)
+			if item.Action != RestoreCreate {
+				item.Current = maskValue(item.Current)
+			}
+		}
+
+		in.Options.Tags = restoreTags(archived[sources[i]].Tags, currentTags, in.Options.Tags)
+
+		if item.Action != RestoreUnchanged {
+			toRestore = append(toRestore, in)
+		}
+
+		result.Changes = append(result.Changes, item)
+	}
+
+	if err := checkSharedOwnership(ctx, st, config, current, toRestore); err != nil {
+		return RestoreDoc{}, nil, err
+	}
+
+	return result, toRestore, nil
+}
+
+// restoreInputs maps params of the archive to the prefixes of the config.
+// It also returns the names in the archive, in the same order as inputs.
+func restoreInputs(archive Archive, config *c.Config) ([]store.ConfigInput, []string) {
+	inputs := []store.ConfigInput{}
+	sources := []string{}
+
+	for _, p := range archive.Params {
+		if restoreSkipOrphans && p.Orphan {
+			continue
+		}
+
+		name := p.Name
+		switch {
+		case strings.HasPrefix(name, archive.SharedPrefix):
+			name = config.SharedPrefix + strings.TrimPrefix(name, archive.SharedPrefix)
+		case strings.HasPrefix(name, archive.Prefix):
+			name = config.Prefix + strings.TrimPrefix(name, archive.Prefix)
+		}
+
+		in := store.ConfigInput{
+			Name:        name,
+			Value:       p.Value,
+			Secret:      p.Secret,
+			Shared:      p.Shared,
+			Description: p.Description,
+			Options:     config.Options,
+		}
+
+		for _, d := range config.All {
+			if d.Name == name {
+				in.Options = d.Options
+				break
+			}
+		}
+
+		if !p.Secret && p.Type != "String" && p.Type != "SecureString" {
+			in.Options.Type = p.Type
+		}
+
+		if p.DataType != "" && p.DataType != "text" && p.DataType != "SecureString" {
+			in.Options.DataType = p.DataType
+		}
+
+		inputs = append(inputs, in)
+		sources = append(sources, p.Name)
+	}
+
+	return inputs, sources
+}
+
+// restoreTags merges tags of an archived param with the current tags of the
+// target and the tags declared in config file, in increasing precedence. The
+// owner of a shared param is kept. Tags reserved by aws, eg. of deleted or
+// replicated secrets, are not restored.
+func restoreTags(archived map[string]string, current map[string]string, declared map[string]string) map[string]string {
+	tags := map[string]string{}
+
+	for _, from := range []map[string]string{archived, current} {
+		for k, v := range from {
+			if !strings.HasPrefix(k, "aws:") {
+				tags[k] = v
+			}
+		}
+	}
+
+	for k, v := range declared {
+		if _, ok := tags[c.OwnerTag]; ok && (k == c.OwnerTag || k == "service") {
+			continue
+		}
+		tags[k] = v
+	}
+
+	return tags
+}
+
+func printRestoreChanges(out *os.File, changes []RestoreItemDoc) {
+	w := tabwriter.NewWriter(out, 0, 8, 2, '\t', 0)
+
+	fmt.Fprintln(w, "Action\tName\tCurrent\tRestored\tFrom")
+
+	for _, item := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item.Action, item.Name, oneLine(item.Current), oneLine(item.Value), item.From)
+	}
+	fmt.Fprintln(w, "---")
+	w.Flush()
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+)
+
+func TestRestoreTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		archived map[string]string
+		current  map[string]string
+		declared map[string]string
+		want     map[string]string
+	}{
+		{
+			name:     "archived only",
+			archived: map[string]string{"team": "a"},
+			want:     map[string]string{"team": "a"},
+		},
+		{
+			name:     "current over archived",
+			archived: map[string]string{"team": "a", "cost-center": "1"},
+			current:  map[string]string{"team": "b", "extra": "x"},
+			want:     map[string]string{"team": "b", "cost-center": "1", "extra": "x"},
+		},
+		{
+			name:     "declared over current",
+			archived: map[string]string{"stage": "prod"},
+			current:  map[string]string{"stage": "old"},
+			declared: map[string]string{"stage": "dev"},
+			want:     map[string]string{"stage": "dev"},
+		},
+		{
+			name:     "reserved tags are skipped",
+			archived: map[string]string{"aws:cloudformation:stack-name": "s", "team": "a"},
+			current:  map[string]string{"aws:secretsmanager:owningService": "rds"},
+			want:     map[string]string{"team": "a"},
+		},
+		{
+			name:     "owner is kept",
+			archived: map[string]string{c.OwnerTag: "billing"},
+			declared: map[string]string{c.OwnerTag: "api", "service": "api", c.UsedByTag("api"): "true"},
+			want:     map[string]string{c.OwnerTag: "billing", c.UsedByTag("api"): "true"},
+		},
+		{
+			name:     "owner of current is kept",
+			archived: map[string]string{c.OwnerTag: "billing"},
+			current:  map[string]string{c.OwnerTag: "orders"},
+			declared: map[string]string{c.OwnerTag: "api"},
+			want:     map[string]string{c.OwnerTag: "orders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restoreTags(tt.archived, tt.current, tt.declared); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restoreTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreInputs(t *testing.T) {
+	archive := Archive{
+		Prefix:       "/prod/api/",
+		SharedPrefix: "/prod/shared/",
+		Params: []ArchiveParam{
+			{Name: "/prod/api/HOST", Value: "h", Type: "String"},
+			{Name: "/prod/shared/REGION", Value: "r", Type: "String", Shared: true},
+			{Name: "/prod/api/OLD", Value: "o", Type: "String", Orphan: true},
+			{Name: "/prod/api/IDS", Value: "a,b", Type: "StringList"},
+		},
+	}
+
+	config := &c.Config{
+		Prefix:       "/dev/api/",
+		SharedPrefix: "/dev/shared/",
+		All:          []store.ConfigInput{{Name: "/dev/api/HOST", Options: store.ConfigOptions{Tier: "Advanced"}}},
+	}
+
+	tests := []struct {
+		name        string
+		skipOrphans bool
+		want        []string
+	}{
+		{name: "all", want: []string{"/dev/api/HOST", "/dev/shared/REGION", "/dev/api/OLD", "/dev/api/IDS"}},
+		{name: "skip orphans", skipOrphans: true, want: []string{"/dev/api/HOST", "/dev/shared/REGION", "/dev/api/IDS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreSkipOrphans = tt.skipOrphans
+			defer func() { restoreSkipOrphans = false }()
+
+			inputs, sources := restoreInputs(archive, config)
+
+			names := []string{}
+			for _, in := range inputs {
+				names = append(names, in.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("restoreInputs() = %v, want %v", names, tt.want)
+			}
+
+			if len(sources) != len(inputs) {
+				t.Fatalf("restoreInputs() returned %d sources for %d inputs", len(sources), len(inputs))
+			}
+
+			if inputs[0].Options.Tier != "Advanced" {
+				t.Errorf("options of declared key are not used")
+			}
+
+			if last := inputs[len(inputs)-1]; last.Options.Type != "StringList" {
+				t.Errorf("type of %s = %q, want StringList", last.Name, last.Options.Type)
+			}
+		})
+	}
+}
+
+func TestRestoreChanges(t *testing.T) {
+	archive := Archive{
+		Prefix:       "/prod/api/",
+		SharedPrefix: "/prod/shared/",
+		Params: []ArchiveParam{
+			{Name: "/prod/api/HOST", Value: "h", Type: "String"},
+			{Name: "/prod/api/PORT", Value: "80", Type: "String"},
+			{Name: "/prod/api/API_KEY", Value: "new-secret", Type: "SecureString", Secret: true},
+			{Name: "/prod/shared/REGION", Value: "us-east-1", Type: "String", Shared: true},
+		},
+	}
+
+	owned := param("/dev/shared/REGION", "eu-west-1")
+	owned.Tags = map[string]string{c.OwnerTag: "billing"}
+
+	st := &memStore{params: []store.Config{
+		param("/dev/api/PORT", "80"),
+		param("/dev/api/API_KEY", "old-secret"),
+		owned,
+	}}
+
+	t.Run("preview", func(t *testing.T) {
+		config := &c.Config{
+			Service:        "api",
+			Prefix:         "/dev/api/",
+			SharedPrefix:   "/dev/shared/",
+			SharedConflict: c.SharedConflictWarn,
+			Options:        store.ConfigOptions{Tags: map[string]string{c.OwnerTag: "api", "service": "api"}},
+		}
+
+		doc, toRestore, err := restoreChanges(context.Background(), st, config, archive)
+
+		if err != nil {
+			t.Fatalf("restoreChanges() error = %v", err)
+		}
+
+		want := []RestoreItemDoc{
+			{Name: "/dev/api/HOST", From: "/prod/api/HOST", Action: RestoreCreate, Value: "h"},
+			{Name: "/dev/api/PORT", From: "/prod/api/PORT", Action: RestoreUnchanged, Current: "80", Value: "80"},
+			{Name: "/dev/api/API_KEY", From: "/prod/api/API_KEY", Action: RestoreUpdate, Secret: true, Current: maskValue("old-secret"), Value: maskValue("new-secret")},
+			{Name: "/dev/shared/REGION", From: "/prod/shared/REGION", Action: RestoreUpdate, Current: "eu-west-1", Value: "us-east-1"},
+		}
+
+		if !reflect.DeepEqual(doc.Changes, want) {
+			t.Errorf("changes = %+v, want %+v", doc.Changes, want)
+		}
+
+		if len(toRestore) != 3 {
+			t.Fatalf("restoring %d params, want 3", len(toRestore))
+		}
+
+		if owner := toRestore[2].Options.Tags[c.OwnerTag]; owner == "api" {
+			t.Errorf("owner of shared param taken over by api")
+		}
+	})
+
+	t.Run("conflict with owner fails", func(t *testing.T) {
+		config := &c.Config{Service: "api", Prefix: "/dev/api/", SharedPrefix: "/dev/shared/", SharedConflict: c.SharedConflictFail}
+
+		_, _, err := restoreChanges(context.Background(), st, config, archive)
+
+		if err == nil || !strings.Contains(err.Error(), "owned by billing") {
+			t.Errorf("restoreChanges() error = %v, want conflict", err)
+		}
+	})
+}
+
+// describedStore returns descriptions separately from listed params, like ssm
+type describedStore struct {
+	*memStore
+	descriptions map[string]string
+}
+
+func (d *describedStore) GetDescriptions(ctx context.Context, path string) (map[string]string, error) {
+	result := map[string]string{}
+	for name, description := range d.descriptions {
+		if strings.HasPrefix(name, path) {
+			result[name] = description
+		}
+	}
+	return result, nil
+}
+
+func TestSnapshotDescriptions(t *testing.T) {
+	st := &describedStore{
+		memStore: &memStore{params: []store.Config{
+			param("/dev/api/HOST", "h"),
+			param("/dev/api/OLD", "o"),
+			param("/dev/shared/REGION", "r"),
+		}},
+		descriptions: map[string]string{
+			"/dev/api/OLD":       "orphan description",
+			"/dev/shared/REGION": "shared description",
+		},
+	}
+
+	config := &c.Config{
+		Prefix:       "/dev/api/",
+		SharedPrefix: "/dev/shared/",
+		All: []store.ConfigInput{
+			{Name: "/dev/api/HOST", Description: "declared description"},
+			{Name: "/dev/shared/REGION", Shared: true},
+		},
+	}
+
+	archive, err := snapshot(context.Background(), st, config)
+
+	if err != nil {
+		t.Fatalf("snapshot() error = %v", err)
+	}
+
+	got := map[string]string{}
+	for _, p := range archive.Params {
+		got[p.Name] = p.Description
+	}
+
+	want := map[string]string{
+		"/dev/api/HOST":      "declared description",
+		"/dev/api/OLD":       "orphan description",
+		"/dev/shared/REGION": "shared description",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("descriptions = %v, want %v", got, want)
+	}
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
 	"strings"
 
 	c "github.com/adikari/safebox/v2/config"
@@ -11,14 +13,21 @@ import (
 var (
 	stage        string
 	pathToConfig string
+	outputFormat string
+	awsAuth      c.AwsAuth
+	endpointUrl  string
 	TimeFormat   = "2006-01-02 15:04:05"
 )
 
 var rootCmd = &cobra.Command{
-	Use:          "safebox",
-	Short:        "SafeBox is a secret manager CLI program",
-	Long:         `A Fast and Flexible secret manager built with love by adikari in Go.`,
-	SilenceUsage: true,
+	Use:           "safebox",
+	Short:         "SafeBox is a secret manager CLI program",
+	Long:          `A Fast and Flexible secret manager built with love by adikari in Go.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		return validateOutputFormat()
+	},
 	Run: func(cmd *cobra.Command, _ []string) {
 		cmd.Usage()
 	},
@@ -29,23 +38,50 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&pathToConfig, "config", "c", "", "path to safebox configuration file")
 	rootCmd.MarkFlagFilename("config")
+
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", TableOutput, "output format (table, json, yaml)")
+
+	rootCmd.PersistentFlags().StringVar(&awsAuth.Profile, "profile", "", "aws profile to use")
+	rootCmd.PersistentFlags().StringVar(&awsAuth.RoleArn, "role-arn", "", "aws role to assume")
+	rootCmd.PersistentFlags().StringVar(&awsAuth.ExternalId, "external-id", "", "external id of the role to assume")
+	rootCmd.PersistentFlags().StringVar(&awsAuth.MfaSerial, "mfa-serial", "", "mfa device of the role to assume")
+	rootCmd.PersistentFlags().StringVar(&endpointUrl, "endpoint-url", "", "endpoint of all aws services, eg. http://localhost:4566 for localstack")
 }
 
 func Execute(version string) {
 	rootCmd.Version = version
 
-	if cmd, err := rootCmd.ExecuteC(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if cmd, err := rootCmd.ExecuteContextC(ctx); err != nil {
+		// exit code of a command run by exec
+		if code, ok := err.(exitCode); ok {
+			stop()
+			os.Exit(int(code))
+		}
+
+		printError(err)
+
 		if strings.Contains(err.Error(), "arg(s)") || strings.Contains(err.Error(), "usage") {
 			cmd.Usage()
 		}
 
+		stop()
 		os.Exit(1)
 	}
 }
 
-func loadConfig() (*c.Config, error) {
-	return c.Load(c.LoadConfigInput{
+func loadConfig(ctx context.Context) (*c.Config, error) {
+	return loadStageConfig(ctx, stage)
+}
+
+func loadStageConfig(ctx context.Context, stage string) (*c.Config, error) {
+	return c.Load(ctx, c.LoadConfigInput{
 		Path:  pathToConfig,
 		Stage: stage,
+		Auth:  awsAuth,
+
+		EndpointUrl: endpointUrl,
 	})
 }
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchStages []string
+	searchValues bool
+	searchRegex  bool
+	searchPath   string
+	searchReveal bool
+
+	searchCmd = &cobra.Command{
+		Use:   "search <pattern>",
+		Short: "Searches keys across stages",
+		Long: `Searches keys, and values with --values, of every stage declared in the
+config file and prints the matches as a key by stage matrix. Secrets are
+masked and their values are not matched, unless --reveal is passed.
+
+With --path all parameters under the path are searched instead, with the
+first segment below the path as column, eg. the stage for --path /.`,
+		Example: `  safebox search FEATURE_
+  safebox search --values --stages dev,prod example.com
+  safebox search --regex '^DB_(HOST|PORT)$' --path /`,
+		Args: cobra.ExactArgs(1),
+		RunE: search,
+	}
+)
+
+type SearchDoc struct {
+	Columns []string      `json:"columns" yaml:"columns"`
+	Matches []SearchMatch `json:"matches" yaml:"matches"`
+}
+
+type SearchMatch struct {
+	Key    string                 `json:"key" yaml:"key"`
+	Values map[string]SearchValue `json:"values" yaml:"values"`
+}
+
+type SearchValue struct {
+	Name   string `json:"name" yaml:"name"`
+	Value  string `json:"value" yaml:"value"`
+	Secret bool   `json:"secret" yaml:"secret"`
+	Masked bool   `json:"masked,omitempty" yaml:"masked,omitempty"`
+}
+
+func init() {
+	searchCmd.Flags().StringSliceVar(&searchStages, "stages", []string{}, "stages to search (default is all stages declared in config file)")
+	searchCmd.Flags().BoolVar(&searchValues, "values", false, "also match values")
+	searchCmd.Flags().BoolVarP(&searchRegex, "regex", "e", false, "pattern is a regular expression")
+	searchCmd.Flags().StringVar(&searchPath, "path", "", "search all parameters under the path instead of stages")
+	searchCmd.Flags().BoolVar(&searchReveal, "reveal", false, "show and match values of secrets")
+	searchCmd.Flags().BoolVarP(&revealYes, "yes", "y", false, "do not ask for confirmation when revealing secrets")
+
+	rootCmd.AddCommand(searchCmd)
+}
+
+func search(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	match, err := matcher(args[0], searchRegex)
+
+	if err != nil {
+		return err
+	}
+
+	if searchReveal {
+		if err := confirmReveal(); err != nil {
+			return err
+		}
+	}
+
+	result := SearchDoc{Columns: []string{}, Matches: []SearchMatch{}}
+	rows := map[string]*SearchMatch{}
+	add := searchAdder(rows, match)
+
+	if searchPath != "" {
+		result.Columns, err = searchPathConfigs(ctx, config, searchPath, add)
+	} else {
+		result.Columns, err = searchStageConfigs(ctx, config, add)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		result.Matches = append(result.Matches, *row)
+	}
+
+	sort.Slice(result.Matches, func(i, j int) bool { return result.Matches[i].Key < result.Matches[j].Key })
+
+	return printResult("search", config, result, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+
+		fmt.Fprintf(w, "Key\t%s\n", strings.Join(result.Columns, "\t"))
+
+		for _, m := range result.Matches {
+			cells := []string{m.Key}
+			for _, column := range result.Columns {
+				if v, ok := m.Values[column]; ok {
+					cells = append(cells, oneLine(v.Value))
+				} else {
+					cells = append(cells, "-")
+				}
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		}
+		fmt.Fprintln(w, "---")
+		w.Flush()
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("matching keys = %d", len(result.Matches)),
+			Config:  *config,
+		})
+	})
+}
+
+// searchStageConfigs reads declared keys, orphans and shared parameters of
+// each stage. Shared keys are prefixed with shared/.
+func searchStageConfigs(ctx context.Context, config *c.Config, add func(string, string, store.Config, bool) bool) ([]string, error) {
+	stages := searchStages
+	if len(stages) == 0 {
+		stages = config.DeclaredStages
+	}
+	if len(stages) == 0 {
+		stages = []string{config.Stage}
+	}
+
+	for _, s := range stages {
+		cfg := config
+		var err error
+
+		if s != config.Stage {
+			if cfg, err = loadStageConfig(ctx, s); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to load config of stage %s", s))
+			}
+		}
+
+		st, err := store.GetStore(ctx, store.StoreConfig{
+			Provider: cfg.Provider,
+			Region:   cfg.Region,
+			FilePath: cfg.Filepath,
+			Session:  cfg.Session,
+		})
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to instantiate store")
+		}
+
+		params, err := st.GetMany(ctx, cfg.All)
+
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to read params of stage %s", s))
+		}
+
+		for _, path := range []string{cfg.Prefix, cfg.SharedPrefix} {
+			existing, err := st.GetByPath(ctx, path)
+
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to read params of stage %s", s))
+			}
+
+			params = append(params, existing...)
+		}
+
+		seen := map[string]bool{}
+
+		for _, p := range params {
+			if seen[*p.Name] {
+				continue
+			}
+			seen[*p.Name] = true
+
+			key := strings.TrimPrefix(*p.Name, cfg.Prefix)
+			if strings.HasPrefix(*p.Name, cfg.SharedPrefix) {
+				key = "shared/" + strings.TrimPrefix(*p.Name, cfg.SharedPrefix)
+			}
+
+			add(stageColumn(s), key, p, isSecret(p, cfg))
+		}
+	}
+
+	columns := []string{}
+	for _, s := range stages {
+		columns = append(columns, stageColumn(s))
+	}
+
+	return columns, nil
+}
+
+func stageColumn(stage string) string {
+	if stage == "" {
+		return "default"
+	}
+
+	return stage
+}
+
+// searchPathConfigs searches the store of the stage under path
+func searchPathConfigs(ctx context.Context, config *c.Config, path string, add func(string, string, store.Config, bool) bool) ([]string, error) {
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to instantiate store")
+	}
+
+	return searchTree(ctx, st, config, path, add)
+}
+
+// searchTree reads every parameter under path, using the first segment below
+// the path as column
+func searchTree(ctx context.Context, st store.Store, config *c.Config, path string, add func(string, string, store.Config, bool) bool) ([]string, error) {
+	if !strings.HasSuffix(path, "/") {
+		path = path + "/"
+	}
+
+	var params []store.Config
+	var err error
+
+	if t, ok := st.(store.TreeReader); ok {
+		params, err = t.GetTree(ctx, path)
+	} else {
+		params, err = st.GetByPath(ctx, path)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list params by path")
+	}
+
+	columns := []string{}
+	seen := map[string]bool{}
+
+	for _, p := range params {
+		column, key := path, strings.TrimPrefix(*p.Name, path)
+
+		if parts := strings.SplitN(key, "/", 2); len(parts) == 2 {
+			column, key = parts[0], parts[1]
+		}
+
+		if add(column, key, p, isSecret(p, config)) && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+
+	sort.Strings(columns)
+
+	return columns, nil
+}
+
+// searchAdder returns a function that adds a param to rows when its key, or
+// value with --values, matches. Values of secrets are masked and only matched
+// with --reveal.
+func searchAdder(rows map[string]*SearchMatch, match func(string) bool) func(string, string, store.Config, bool) bool {
+	return func(column string, key string, p store.Config, secret bool) bool {
+		value := ""
+		if p.Value != nil {
+			value = *p.Value
+		}
+
+		matchValue := searchValues && (!secret || searchReveal)
+
+		if !match(key) && !(matchValue && match(value)) {
+			return false
+		}
+
+		row, ok := rows[key]
+		if !ok {
+			row = &SearchMatch{Key: key, Values: map[string]SearchValue{}}
+			rows[key] = row
+		}
+
+		v := SearchValue{Name: *p.Name, Value: value, Secret: secret}
+		if secret && !searchReveal {
+			v.Value = maskValue(value)
+			v.Masked = true
+		}
+
+		row.Values[column] = v
+
+		return true
+	}
+}
+
+func isSecret(p store.Config, config *c.Config) bool {
+	for _, d := range config.All {
+		if d.Name == *p.Name {
+			return d.Secret
+		}
+	}
+
+	return p.Type == "SecureString"
+}
+
+// matcher matches case insensitive substrings, or a regular expression
+func matcher(pattern string, regex bool) (func(string) bool, error) {
+	if !regex {
+		pattern = strings.ToLower(pattern)
+		return func(s string) bool {
+			return strings.Contains(strings.ToLower(s), pattern)
+		}, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid pattern")
+	}
+
+	return re.MatchString, nil
+}
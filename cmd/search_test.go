@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/adikari/safebox/v2/store/storetest"
+	a "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		regex   bool
+		input   string
+		want    bool
+	}{
+		{"feature_", false, "NEW_FEATURE_FLAG", true},
+		{"FEATURE", false, "DB_HOST", false},
+		{"DB_(HOST|PORT)", false, "DB_HOST", false},
+		{"^DB_(HOST|PORT)$", true, "DB_HOST", true},
+		{"^DB_(HOST|PORT)$", true, "OLD_DB_HOST", false},
+		{"^db_host$", true, "DB_HOST", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.input, func(t *testing.T) {
+			match, err := matcher(tt.pattern, tt.regex)
+
+			if err != nil {
+				t.Fatalf("matcher() error = %v", err)
+			}
+
+			if got := match(tt.input); got != tt.want {
+				t.Errorf("match(%s) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherInvalidPattern(t *testing.T) {
+	if _, err := matcher("DB_(HOST", true); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("matcher() error = %v, want invalid pattern", err)
+	}
+
+	if _, err := matcher("DB_(HOST", false); err != nil {
+		t.Errorf("matcher() error = %v, want substrings to match as is", err)
+	}
+}
+
+func TestSearchTree(t *testing.T) {
+	fake := storetest.NewFakeSSM("/dev/api/DB_HOST", "/dev/shared/DB_HOST", "/prod/api/DB_HOST", "/prod/api/PORT")
+	fake.Params["/prod/api/DB_PASSWORD"] = types.Parameter{Name: a.String("/prod/api/DB_PASSWORD"), Value: a.String("example.com secret"), Type: types.ParameterTypeSecureString}
+	fake.Params["/prod/api/API_URL"] = types.Parameter{Name: a.String("/prod/api/API_URL"), Value: a.String("https://example.com"), Type: types.ParameterTypeString}
+	st := store.NewSSMStoreWithClient(fake)
+
+	defer func(v, r bool) { searchValues, searchReveal = v, r }(searchValues, searchReveal)
+
+	tests := []struct {
+		name        string
+		pattern     string
+		values      bool
+		reveal      bool
+		wantColumns []string
+		want        map[string]map[string]SearchValue
+	}{
+		{
+			name:        "columns are stages",
+			pattern:     "db_host",
+			wantColumns: []string{"dev", "prod"},
+			want: map[string]map[string]SearchValue{
+				"api/DB_HOST": {
+					"dev":  {Name: "/dev/api/DB_HOST", Value: "value of /dev/api/DB_HOST"},
+					"prod": {Name: "/prod/api/DB_HOST", Value: "value of /prod/api/DB_HOST"},
+				},
+				"shared/DB_HOST": {
+					"dev": {Name: "/dev/shared/DB_HOST", Value: "value of /dev/shared/DB_HOST"},
+				},
+			},
+		},
+		{
+			name:        "secrets are masked",
+			pattern:     "PASSWORD",
+			wantColumns: []string{"prod"},
+			want: map[string]map[string]SearchValue{
+				"api/DB_PASSWORD": {
+					"prod": {Name: "/prod/api/DB_PASSWORD", Value: maskValue("example.com secret"), Secret: true, Masked: true},
+				},
+			},
+		},
+		{
+			name:        "values of secrets are not matched",
+			pattern:     "example.com",
+			values:      true,
+			wantColumns: []string{"prod"},
+			want: map[string]map[string]SearchValue{
+				"api/API_URL": {
+					"prod": {Name: "/prod/api/API_URL", Value: "https://example.com"},
+				},
+			},
+		},
+		{
+			name:        "values of secrets are matched with reveal",
+			pattern:     "example.com",
+			values:      true,
+			reveal:      true,
+			wantColumns: []string{"prod"},
+			want: map[string]map[string]SearchValue{
+				"api/API_URL": {
+					"prod": {Name: "/prod/api/API_URL", Value: "https://example.com"},
+				},
+				"api/DB_PASSWORD": {
+					"prod": {Name: "/prod/api/DB_PASSWORD", Value: "example.com secret", Secret: true},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searchValues, searchReveal = tt.values, tt.reveal
+
+			match, err := matcher(tt.pattern, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rows := map[string]*SearchMatch{}
+			columns, err := searchTree(context.Background(), st, &c.Config{}, "/", searchAdder(rows, match))
+
+			if err != nil {
+				t.Fatalf("searchTree() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(columns, tt.wantColumns) {
+				t.Errorf("columns = %v, want %v", columns, tt.wantColumns)
+			}
+
+			got := map[string]map[string]SearchValue{}
+			for key, row := range rows {
+				got[key] = row.Values
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
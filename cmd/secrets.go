@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const secretEnvPrefix = "SAFEBOX_SECRET_"
+
+// providedSecrets returns secrets with values supplied without a prompt,
+// from --secrets-file and SAFEBOX_SECRET_<KEY> environment variables.
+// Environment variables take precedence. Shared secrets are looked up as
+// shared.<KEY> and SAFEBOX_SECRET_SHARED_<KEY> so they are never set by
+// accident through a service secret of the same name. A variable naming
+// more than one secret, eg. of service secret SHARED_<KEY>, is refused.
+func providedSecrets(secrets []store.ConfigInput) ([]store.ConfigInput, error) {
+	values := map[string]string{}
+	envNames := map[string]string{}
+
+	if secretsFile != "" {
+		b, err := readSecretsFile(secretsFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := yaml.Unmarshal(b, &values); err != nil {
+			return nil, errors.Wrap(err, "secrets file must be a map of key to value")
+		}
+	}
+
+	result := []store.ConfigInput{}
+
+	for _, s := range secrets {
+		key := s.Key()
+		if s.Shared {
+			key = "shared." + key
+		}
+
+		value, ok := values[key]
+		env := secretEnvPrefix + envKey(strings.Replace(key, ".", "_", -1))
+
+		if v, found := os.LookupEnv(env); found {
+			if other, seen := envNames[env]; seen && other != key {
+				return nil, fmt.Errorf("%s names both %s and %s. use --secrets-file instead", env, other, key)
+			}
+			envNames[env] = key
+			value, ok = v, true
+		}
+
+		if !ok {
+			continue
+		}
+
+		if value == "" {
+			return nil, fmt.Errorf("%s must not be empty", key)
+		}
+
+		s.Value = value
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+func readSecretsFile(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	if strings.HasSuffix(path, ".gpg") || strings.HasSuffix(path, ".asc") {
+		cmd := exec.Command("gpg", "--quiet", "--batch", "--decrypt", path)
+		cmd.Stderr = os.Stderr
+
+		b, err := cmd.Output()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt secrets file")
+		}
+		return b, nil
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+func findConfig(name string, configs []store.Config) *store.Config {
+	for i := range configs {
+		if *configs[i].Name == name {
+			return &configs[i]
+		}
+	}
+
+	return nil
+}
+
+// excludeInputs returns inputs whose names are not in exclude
+func excludeInputs(inputs []store.ConfigInput, exclude []store.ConfigInput) []store.ConfigInput {
+	var result []store.ConfigInput
+
+loop:
+	for _, i := range inputs {
+		for _, e := range exclude {
+			if i.Name == e.Name {
+				continue loop
+			}
+		}
+		result = append(result, i)
+	}
+
+	return result
+}
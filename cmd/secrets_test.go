@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/adikari/safebox/v2/store"
+)
+
+func TestProvidedSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []store.ConfigInput
+		file    string
+		env     map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "from env",
+			secrets: []store.ConfigInput{{Name: "/dev/api/API_KEY"}, {Name: "/dev/api/OTHER"}},
+			env:     map[string]string{"SAFEBOX_SECRET_API_KEY": "env"},
+			want:    map[string]string{"/dev/api/API_KEY": "env"},
+		},
+		{
+			name:    "env over file",
+			secrets: []store.ConfigInput{{Name: "/dev/api/API_KEY"}, {Name: "/dev/api/OTHER"}},
+			file:    "API_KEY: file\nOTHER: other\n",
+			env:     map[string]string{"SAFEBOX_SECRET_API_KEY": "env"},
+			want:    map[string]string{"/dev/api/API_KEY": "env", "/dev/api/OTHER": "other"},
+		},
+		{
+			name:    "shared not set by service name",
+			secrets: []store.ConfigInput{{Name: "/dev/shared/API_KEY", Shared: true}},
+			file:    "API_KEY: file\n",
+			env:     map[string]string{"SAFEBOX_SECRET_API_KEY": "env"},
+			want:    map[string]string{},
+		},
+		{
+			name:    "shared",
+			secrets: []store.ConfigInput{{Name: "/dev/shared/API_KEY", Shared: true}, {Name: "/dev/shared/OTHER", Shared: true}},
+			file:    "shared.OTHER: file\n",
+			env:     map[string]string{"SAFEBOX_SECRET_SHARED_API_KEY": "env"},
+			want:    map[string]string{"/dev/shared/API_KEY": "env", "/dev/shared/OTHER": "file"},
+		},
+		{
+			name:    "ambiguous variable",
+			secrets: []store.ConfigInput{{Name: "/dev/api/SHARED_API_KEY"}, {Name: "/dev/shared/API_KEY", Shared: true}},
+			env:     map[string]string{"SAFEBOX_SECRET_SHARED_API_KEY": "env"},
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous names in file",
+			secrets: []store.ConfigInput{{Name: "/dev/api/SHARED_API_KEY"}, {Name: "/dev/shared/API_KEY", Shared: true}},
+			file:    "SHARED_API_KEY: service\nshared.API_KEY: shared\n",
+			want:    map[string]string{"/dev/api/SHARED_API_KEY": "service", "/dev/shared/API_KEY": "shared"},
+		},
+		{
+			name:    "empty",
+			secrets: []store.ConfigInput{{Name: "/dev/api/API_KEY"}},
+			env:     map[string]string{"SAFEBOX_SECRET_API_KEY": ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secretsFile = ""
+			defer func() { secretsFile = "" }()
+
+			if tt.file != "" {
+				secretsFile = filepath.Join(t.TempDir(), "secrets.yml")
+				if err := ioutil.WriteFile(secretsFile, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := providedSecrets(tt.secrets)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("providedSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			values := map[string]string{}
+			for _, s := range got {
+				values[s.Name] = s.Value
+			}
+
+			if len(values) != len(tt.want) {
+				t.Errorf("providedSecrets() = %v, want %v", values, tt.want)
+			}
+
+			for name, want := range tt.want {
+				if values[name] != want {
+					t.Errorf("value of %s = %q, want %q", name, values[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveHost  string
+	servePort  int
+	serveToken string
+
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serves configurations over http for local development",
+		Long: `Serves configurations of a stage over http for local development.
+
+  GET /v1/configs         all declared configs and secrets as a json map,
+                          grouped under shared and service with ?nested=true
+  GET /v1/configs/<key>   value of a key as plain text
+  POST /                  ssm compatible GetParameter, GetParameters and
+                          GetParametersByPath, eg. for aws sdks
+
+Requests must send the token as "Authorization: Bearer <token>". Aws sdks
+send it as the access key id, eg. AWS_ACCESS_KEY_ID=<token>. Signatures
+are not verified. The token is read from SAFEBOX_SERVE_TOKEN, or generated
+and printed on start.`,
+		RunE: serve,
+	}
+)
+
+var accessKeyId = regexp.MustCompile(`Credential=([^/,\s]+)/`)
+
+func init() {
+	serveCmd.Flags().StringVar(&serveHost, "host", "127.0.0.1", "address to listen on")
+	serveCmd.Flags().IntVar(&servePort, "port", 8200, "port to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "token clients must send (default $SAFEBOX_SERVE_TOKEN or generated)")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+func serve(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate store")
+	}
+
+	token := serveToken
+	if token == "" {
+		token = os.Getenv("SAFEBOX_SERVE_TOKEN")
+	}
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(b)
+		fmt.Fprintf(os.Stderr, "token: %s\n", token)
+	}
+
+	addr := net.JoinHostPort(serveHost, strconv.Itoa(servePort))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           withToken(token, configsHandler(st, config)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s\n", config.Prefix, addr)
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// withToken accepts the token as a bearer token or as the access key id of a
+// sigv4 signed request
+func withToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		given := strings.TrimPrefix(auth, "Bearer ")
+
+		if m := accessKeyId.FindStringSubmatch(auth); m != nil {
+			given = m[1]
+		}
+
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func configsHandler(st store.Store, config *c.Config) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/configs", func(w http.ResponseWriter, r *http.Request) {
+		configs, err := st.GetMany(r.Context(), config.All)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if r.URL.Query().Get("nested") == "true" {
+			writeJson(w, http.StatusOK, structure(configs, config.All, true, false))
+			return
+		}
+
+		params, _ := flatten(configs, config.All)
+		writeJson(w, http.StatusOK, params)
+	})
+
+	mux.HandleFunc("GET /v1/configs/{key}", func(w http.ResponseWriter, r *http.Request) {
+		toGet, err := configsToExport(config.All, []string{r.PathValue("key")})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		configs, err := st.GetMany(r.Context(), toGet)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if len(configs) == 0 {
+			http.Error(w, "not deployed", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, *configs[0].Value)
+	})
+
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		serveSsm(st, config, w, r)
+	})
+
+	return mux
+}
+
+type ssmParameter struct {
+	Name             string
+	Type             string
+	Value            string
+	Version          int64
+	DataType         string
+	LastModifiedDate float64
+}
+
+type ssmRequest struct {
+	Name           string
+	Names          []string
+	Path           string
+	Recursive      bool
+	WithDecryption bool
+}
+
+// serveSsm implements the read api of ssm used by sdks to load parameters.
+// Only keys declared in config file are served.
+func serveSsm(st store.Store, config *c.Config, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	var req ssmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSsmError(w, "ValidationException", err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonSSM.")
+
+	switch action {
+	case "GetParameter":
+		input := declaredInput(config, req.Name)
+		if input == nil {
+			writeSsmError(w, "ParameterNotFound", fmt.Sprintf("parameter %s not found", req.Name))
+			return
+		}
+
+		found, err := st.GetMany(ctx, []store.ConfigInput{*input})
+		if err != nil {
+			writeSsmError(w, "InternalServerError", err.Error())
+			return
+		}
+
+		if len(found) == 0 {
+			writeSsmError(w, "ParameterNotFound", fmt.Sprintf("parameter %s not found", req.Name))
+			return
+		}
+
+		writeJson(w, http.StatusOK, map[string]interface{}{"Parameter": toSsmParameter(found[0], config, req.WithDecryption)})
+	case "GetParameters":
+		inputs := []store.ConfigInput{}
+		for _, name := range req.Names {
+			if input := declaredInput(config, name); input != nil {
+				inputs = append(inputs, *input)
+			}
+		}
+
+		found, err := st.GetMany(ctx, inputs)
+		if err != nil {
+			writeSsmError(w, "InternalServerError", err.Error())
+			return
+		}
+
+		params := []ssmParameter{}
+		invalid := []string{}
+		for _, name := range req.Names {
+			if p := findConfig(name, found); p != nil {
+				params = append(params, toSsmParameter(*p, config, req.WithDecryption))
+			} else {
+				invalid = append(invalid, name)
+			}
+		}
+
+		writeJson(w, http.StatusOK, map[string]interface{}{"Parameters": params, "InvalidParameters": invalid})
+	case "GetParametersByPath":
+		path := strings.TrimSuffix(req.Path, "/") + "/"
+
+		if !strings.HasPrefix(path, config.Prefix) && !strings.HasPrefix(path, config.SharedPrefix) {
+			writeSsmError(w, "AccessDeniedException", fmt.Sprintf("path %s is outside of %s and %s", req.Path, config.Prefix, config.SharedPrefix))
+			return
+		}
+
+		var found []store.Config
+		var err error
+
+		if t, ok := st.(store.TreeReader); ok && req.Recursive {
+			found, err = t.GetTree(ctx, path)
+		} else {
+			found, err = st.GetByPath(ctx, path)
+		}
+
+		if err != nil {
+			writeSsmError(w, "InternalServerError", err.Error())
+			return
+		}
+
+		params := []ssmParameter{}
+		for _, p := range found {
+			if declaredInput(config, *p.Name) == nil {
+				continue
+			}
+			if !req.Recursive && strings.Contains(strings.TrimPrefix(*p.Name, path), "/") {
+				continue
+			}
+			params = append(params, toSsmParameter(p, config, req.WithDecryption))
+		}
+
+		writeJson(w, http.StatusOK, map[string]interface{}{"Parameters": params})
+	default:
+		writeSsmError(w, "UnsupportedOperation", fmt.Sprintf("%s is not supported", action))
+	}
+}
+
+func declaredInput(config *c.Config, name string) *store.ConfigInput {
+	for i := range config.All {
+		if config.All[i].Name == name {
+			return &config.All[i]
+		}
+	}
+
+	return nil
+}
+
+// toSsmParameter masks values of secrets unless decryption is asked for, as
+// ssm does not return plain values of SecureString parameters without it
+func toSsmParameter(p store.Config, config *c.Config, withDecryption bool) ssmParameter {
+	version, _ := strconv.ParseInt(p.Version, 10, 64)
+
+	dataType := p.DataType
+	if dataType == "" || dataType == "SecureString" {
+		dataType = "text"
+	}
+
+	param := ssmParameter{
+		Name:     *p.Name,
+		Type:     p.Type,
+		Value:    *p.Value,
+		Version:  version,
+		DataType: dataType,
+	}
+
+	if !withDecryption && isSecret(p, config) {
+		param.Type = "SecureString"
+		param.Value = maskValue(param.Value)
+	}
+
+	if !p.Modified.IsZero() {
+		param.LastModifiedDate = float64(p.Modified.UnixNano()) / 1e9
+	}
+
+	return param
+}
+
+func writeSsmError(w http.ResponseWriter, code string, message string) {
+	writeJson(w, http.StatusBadRequest, map[string]string{"__type": code, "message": message})
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+)
+
+// memStore keeps parameters in memory, listing paths like ssm
+type memStore struct {
+	params  []store.Config
+	deleted []string
+}
+
+func (m *memStore) PutMany(ctx context.Context, input []store.ConfigInput) error {
+	return nil
+}
+
+func (m *memStore) Get(ctx context.Context, input store.ConfigInput) (*store.Config, error) {
+	if p := findConfig(input.Name, m.params); p != nil {
+		return p, nil
+	}
+	return nil, store.ConfigNotFoundError
+}
+
+func (m *memStore) GetMany(ctx context.Context, inputs []store.ConfigInput) ([]store.Config, error) {
+	result := []store.Config{}
+	for _, in := range inputs {
+		if p := findConfig(in.Name, m.params); p != nil {
+			result = append(result, *p)
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) GetByPath(ctx context.Context, path string) ([]store.Config, error) {
+	result := []store.Config{}
+	for _, p := range m.params {
+		if strings.HasPrefix(*p.Name, path) && !strings.Contains(strings.TrimPrefix(*p.Name, path), "/") {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) GetTree(ctx context.Context, path string) ([]store.Config, error) {
+	result := []store.Config{}
+	for _, p := range m.params {
+		if strings.HasPrefix(*p.Name, path) {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+func (m *memStore) DeleteMany(ctx context.Context, inputs []store.ConfigInput) error {
+	for _, in := range inputs {
+		m.deleted = append(m.deleted, in.Name)
+	}
+	return nil
+}
+
+type ssmResponse struct {
+	Type              string `json:"__type"`
+	Parameter         ssmParameter
+	Parameters        []ssmParameter
+	InvalidParameters []string
+}
+
+func TestServeSsm(t *testing.T) {
+	config := &c.Config{
+		Prefix:       "/dev/api/",
+		SharedPrefix: "/dev/shared/",
+		All: []store.ConfigInput{
+			{Name: "/dev/api/HOST", Value: "h"},
+			{Name: "/dev/api/API_KEY", Secret: true},
+			{Name: "/dev/shared/REGION", Value: "r", Shared: true},
+		},
+	}
+
+	st := &memStore{params: []store.Config{
+		param("/dev/api/HOST", "h"),
+		param("/dev/api/API_KEY", "secret-value"),
+		param("/dev/api/UNDECLARED", "u"),
+		param("/dev/api/nested/DEEP", "d"),
+		param("/dev/shared/REGION", "r"),
+		param("/dev/shared/OTHER", "o"),
+		param("/dev/billing/DB_PASSWORD", "p"),
+	}}
+
+	tests := []struct {
+		name        string
+		action      string
+		body        string
+		wantType    string
+		wantValues  map[string]string
+		wantInvalid []string
+	}{
+		{
+			name:       "declared",
+			action:     "GetParameter",
+			body:       `{"Name":"/dev/api/HOST"}`,
+			wantValues: map[string]string{"/dev/api/HOST": "h"},
+		},
+		{
+			name:     "undeclared",
+			action:   "GetParameter",
+			body:     `{"Name":"/dev/api/UNDECLARED"}`,
+			wantType: "ParameterNotFound",
+		},
+		{
+			name:     "other service",
+			action:   "GetParameter",
+			body:     `{"Name":"/dev/billing/DB_PASSWORD","WithDecryption":true}`,
+			wantType: "ParameterNotFound",
+		},
+		{
+			name:       "secret is masked",
+			action:     "GetParameter",
+			body:       `{"Name":"/dev/api/API_KEY"}`,
+			wantValues: map[string]string{"/dev/api/API_KEY": maskValue("secret-value")},
+		},
+		{
+			name:       "secret with decryption",
+			action:     "GetParameter",
+			body:       `{"Name":"/dev/api/API_KEY","WithDecryption":true}`,
+			wantValues: map[string]string{"/dev/api/API_KEY": "secret-value"},
+		},
+		{
+			name:        "names",
+			action:      "GetParameters",
+			body:        `{"Names":["/dev/api/HOST","/dev/shared/REGION","/dev/billing/DB_PASSWORD","/dev/api/UNDECLARED"]}`,
+			wantValues:  map[string]string{"/dev/api/HOST": "h", "/dev/shared/REGION": "r"},
+			wantInvalid: []string{"/dev/billing/DB_PASSWORD", "/dev/api/UNDECLARED"},
+		},
+		{
+			name:       "path",
+			action:     "GetParametersByPath",
+			body:       `{"Path":"/dev/api","WithDecryption":true}`,
+			wantValues: map[string]string{"/dev/api/HOST": "h", "/dev/api/API_KEY": "secret-value"},
+		},
+		{
+			name:       "recursive path",
+			action:     "GetParametersByPath",
+			body:       `{"Path":"/dev/shared/","Recursive":true}`,
+			wantValues: map[string]string{"/dev/shared/REGION": "r"},
+		},
+		{
+			name:     "root path",
+			action:   "GetParametersByPath",
+			body:     `{"Path":"/","Recursive":true}`,
+			wantType: "AccessDeniedException",
+		},
+		{
+			name:     "path of other service",
+			action:   "GetParametersByPath",
+			body:     `{"Path":"/dev/billing/"}`,
+			wantType: "AccessDeniedException",
+		},
+		{
+			name:     "unsupported",
+			action:   "PutParameter",
+			body:     `{"Name":"/dev/api/HOST","Value":"x"}`,
+			wantType: "UnsupportedOperation",
+		},
+	}
+
+	handler := configsHandler(st, config)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("X-Amz-Target", "AmazonSSM."+tt.action)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			var resp ssmResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Type != tt.wantType {
+				t.Fatalf("error type = %q, want %q", resp.Type, tt.wantType)
+			}
+
+			if tt.wantType != "" {
+				return
+			}
+
+			params := resp.Parameters
+			if resp.Parameter.Name != "" {
+				params = append(params, resp.Parameter)
+			}
+
+			values := map[string]string{}
+			for _, p := range params {
+				values[p.Name] = p.Value
+			}
+
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", values, tt.wantValues)
+			}
+
+			sort.Strings(resp.InvalidParameters)
+			sort.Strings(tt.wantInvalid)
+			if len(resp.InvalidParameters)+len(tt.wantInvalid) > 0 && !reflect.DeepEqual(resp.InvalidParameters, tt.wantInvalid) {
+				t.Errorf("invalid parameters = %v, want %v", resp.InvalidParameters, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	tests := []struct {
+		name string
+		auth string
+		want int
+	}{
+		{name: "bearer", auth: "Bearer token", want: http.StatusOK},
+		{name: "sigv4", auth: "AWS4-HMAC-SHA256 Credential=token/20240101/us-east-1/ssm/aws4_request, SignedHeaders=host, Signature=abc", want: http.StatusOK},
+		{name: "wrong token", auth: "Bearer other", want: http.StatusUnauthorized},
+		{name: "missing", auth: "", want: http.StatusUnauthorized},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/configs", nil)
+			req.Header.Set("Authorization", tt.auth)
+			rec := httptest.NewRecorder()
+
+			withToken("token", ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sharedCmd = &cobra.Command{
+		Use:   "shared",
+		Short: "Manages shared parameters",
+	}
+
+	sharedListCmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "Lists shared parameters of the stage with their owner and users",
+		RunE:    sharedList,
+	}
+)
+
+type SharedDoc struct {
+	Name     string   `json:"name" yaml:"name"`
+	Key      string   `json:"key" yaml:"key"`
+	Owner    string   `json:"owner" yaml:"owner"`
+	UsedBy   []string `json:"usedBy" yaml:"usedBy"`
+	Declared bool     `json:"declared" yaml:"declared"`
+}
+
+func init() {
+	sharedCmd.AddCommand(sharedListCmd)
+	rootCmd.AddCommand(sharedCmd)
+}
+
+func sharedList(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to instantiate store")
+	}
+
+	params, err := st.GetByPath(ctx, config.SharedPrefix)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to list shared params")
+	}
+
+	items := []SharedDoc{}
+
+	for _, p := range params {
+		tags, err := tagsOf(ctx, st, p)
+
+		if err != nil {
+			return err
+		}
+
+		item := SharedDoc{Name: *p.Name, Key: p.Key(), Owner: ownerOf(tags), UsedBy: usersOf(tags)}
+
+		for _, d := range config.All {
+			if d.Name == *p.Name {
+				item.Declared = true
+				break
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	return printResult("shared", config, items, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, '\t', 0)
+
+		fmt.Fprintln(w, "Name\tOwner\tUsed By\tDeclared")
+
+		for _, item := range items {
+			declared := "no"
+			if item.Declared {
+				declared = "yes"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Name, item.Owner, strings.Join(item.UsedBy, ", "), declared)
+		}
+		fmt.Fprintln(w, "---")
+		w.Flush()
+
+		PrintSummary(Summary{
+			Message: fmt.Sprintf("Total shared parameters = %d", len(items)),
+			Config:  *config,
+		})
+	})
+}
+
+func usersOf(tags map[string]string) []string {
+	users := []string{}
+
+	for k, v := range tags {
+		if strings.HasPrefix(k, c.UsedByTagPrefix) && v == "true" {
+			users = append(users, strings.TrimPrefix(k, c.UsedByTagPrefix))
+		}
+	}
+
+	sort.Strings(users)
+	return users
+}
+
+// checkSharedOwnership keeps the owner of shared parameters owned by another
+// service, and warns or fails when their value is changed
+func checkSharedOwnership(ctx context.Context, st store.Store, config *c.Config, existing []store.Config, toDeploy []store.ConfigInput) error {
+	for i, input := range toDeploy {
+		if !input.Shared {
+			continue
+		}
+
+		found := findConfig(input.Name, existing)
+		if found == nil {
+			continue
+		}
+
+		tags, err := tagsOf(ctx, st, *found)
+
+		if err != nil {
+			return err
+		}
+
+		owner := ownerOf(tags)
+		if owner == "" || owner == config.Service {
+			continue
+		}
+
+		opts := map[string]string{}
+		for k, v := range input.Options.Tags {
+			if k != c.OwnerTag {
+				opts[k] = v
+			}
+		}
+		toDeploy[i].Options.Tags = opts
+
+		if input.Secret || *found.Value == input.Value {
+			continue
+		}
+
+		msg := fmt.Sprintf("shared key %s is owned by %s and is declared with a different value", input.Key(), owner)
+
+		if config.SharedConflict == c.SharedConflictFail {
+			return errors.New(msg)
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+
+	return nil
+}
+
+// recordSharedUsage tags existing shared parameters as used by the service
+func recordSharedUsage(ctx context.Context, st store.Store, config *c.Config, existing []store.Config) error {
+	w, ok := st.(store.TagWriter)
+
+	if !ok {
+		return nil
+	}
+
+	usedBy := c.UsedByTag(config.Service)
+
+	for _, d := range config.All {
+		if !d.Shared {
+			continue
+		}
+
+		found := findConfig(d.Name, existing)
+		if found == nil {
+			continue
+		}
+
+		tags, err := tagsOf(ctx, st, *found)
+
+		if err != nil {
+			return err
+		}
+
+		if tags[usedBy] == "true" {
+			continue
+		}
+
+		if err := w.PutTags(ctx, d.Name, map[string]string{usedBy: "true"}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
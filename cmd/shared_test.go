@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/adikari/safebox/v2/store/storetest"
+)
+
+func TestCheckSharedOwnership(t *testing.T) {
+	const name = "/dev/shared/API_URL"
+	declaredTags := map[string]string{c.OwnerTag: "api", "stage": "dev"}
+
+	tests := []struct {
+		name     string
+		owner    map[string]string
+		input    store.ConfigInput
+		conflict string
+		wantTags map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "new shared param",
+			input:    store.ConfigInput{Name: "/dev/shared/NEW", Value: "v", Shared: true},
+			wantTags: declaredTags,
+		},
+		{
+			name:     "owned by the service",
+			owner:    map[string]string{c.OwnerTag: "api"},
+			input:    store.ConfigInput{Name: name, Value: "changed", Shared: true},
+			conflict: c.SharedConflictFail,
+			wantTags: declaredTags,
+		},
+		{
+			name:     "owned by another service keeps the owner",
+			owner:    map[string]string{c.OwnerTag: "billing"},
+			input:    store.ConfigInput{Name: name, Value: "value of " + name, Shared: true},
+			conflict: c.SharedConflictFail,
+			wantTags: map[string]string{"stage": "dev"},
+		},
+		{
+			name:     "owned by service tag of another service",
+			owner:    map[string]string{"service": "billing"},
+			input:    store.ConfigInput{Name: name, Value: "value of " + name, Shared: true},
+			wantTags: map[string]string{"stage": "dev"},
+		},
+		{
+			name:     "different value warns",
+			owner:    map[string]string{c.OwnerTag: "billing"},
+			input:    store.ConfigInput{Name: name, Value: "changed", Shared: true},
+			conflict: c.SharedConflictWarn,
+			wantTags: map[string]string{"stage": "dev"},
+		},
+		{
+			name:     "different value fails",
+			owner:    map[string]string{c.OwnerTag: "billing"},
+			input:    store.ConfigInput{Name: name, Value: "changed", Shared: true},
+			conflict: c.SharedConflictFail,
+			wantErr:  "owned by billing and is declared with a different value",
+		},
+		{
+			name:     "values of secrets are not compared",
+			owner:    map[string]string{c.OwnerTag: "billing"},
+			input:    store.ConfigInput{Name: name, Value: "changed", Shared: true, Secret: true},
+			conflict: c.SharedConflictFail,
+			wantTags: map[string]string{"stage": "dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := storetest.NewFakeSSM(name)
+			fake.Tags[name] = tt.owner
+			st := store.NewSSMStoreWithClient(fake)
+
+			config := &c.Config{Service: "api", SharedPrefix: "/dev/shared/", SharedConflict: tt.conflict}
+
+			input := tt.input
+			input.Options.Tags = map[string]string{}
+			for k, v := range declaredTags {
+				input.Options.Tags[k] = v
+			}
+
+			existing, err := st.GetMany(ctx, []store.ConfigInput{input})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			toDeploy := []store.ConfigInput{input}
+			err = checkSharedOwnership(ctx, st, config, existing, toDeploy)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkSharedOwnership() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("checkSharedOwnership() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(toDeploy[0].Options.Tags, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", toDeploy[0].Options.Tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestRecordSharedUsage(t *testing.T) {
+	ctx := context.Background()
+	fake := storetest.NewFakeSSM("/dev/shared/A", "/dev/shared/B", "/dev/api/C")
+	fake.Tags["/dev/shared/B"] = map[string]string{c.UsedByTag("api"): "true"}
+	st := store.NewSSMStoreWithClient(fake)
+
+	config := &c.Config{
+		Service: "api",
+		All: []store.ConfigInput{
+			{Name: "/dev/shared/A", Shared: true},
+			{Name: "/dev/shared/B", Shared: true},
+			{Name: "/dev/shared/MISSING", Shared: true},
+			{Name: "/dev/api/C"},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		existing, err := st.GetMany(ctx, config.All)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := recordSharedUsage(ctx, st, config, existing); err != nil {
+			t.Fatalf("recordSharedUsage() error = %v", err)
+		}
+	}
+
+	if fake.Tagged != 1 {
+		t.Errorf("AddTagsToResource called %d times, want 1", fake.Tagged)
+	}
+
+	want := map[string]string{c.UsedByTag("api"): "true"}
+	if !reflect.DeepEqual(fake.Tags["/dev/shared/A"], want) {
+		t.Errorf("tags of /dev/shared/A = %v, want %v", fake.Tags["/dev/shared/A"], want)
+	}
+
+	for _, name := range []string{"/dev/shared/MISSING", "/dev/api/C"} {
+		if _, ok := fake.Tags[name]; ok {
+			t.Errorf("%s is tagged", name)
+		}
+	}
+}
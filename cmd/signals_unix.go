@@ -0,0 +1,10 @@
+//go:build !windows
+
+package cmd
+
+import "syscall"
+
+var platformSignals = map[string]syscall.Signal{
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
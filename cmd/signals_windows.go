@@ -0,0 +1,7 @@
+//go:build windows
+
+package cmd
+
+import "syscall"
+
+var platformSignals = map[string]syscall.Signal{}
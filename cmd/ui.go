@@ -0,0 +1,611 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adikari/safebox/v2/audit"
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browses and edits configurations in a terminal ui",
+	Long: `Browses stages and keys of the config file interactively.
+
+Selecting a key shows its metadata and version history, reveals its value
+and edits or deletes it. Every change is confirmed before it is written and
+recorded in the audit log. Protected keys and shared keys owned by another
+service are never deleted.`,
+	RunE: ui,
+}
+
+const (
+	uiNewKey      = "+ new key"
+	uiChangeStage = "~ change stage"
+	uiQuit        = "x quit"
+	uiOtherStage  = "other..."
+
+	uiDetails = "details"
+	uiReveal  = "reveal value"
+	uiHistory = "history"
+	uiEdit    = "edit value"
+	uiSet     = "set value"
+	uiDelete  = "delete"
+	uiBack    = "back"
+)
+
+// errQuit ends the ui from any menu
+var errQuit = errors.New("quit")
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
+
+func ui(cmd *cobra.Command, _ []string) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("ui requires an interactive terminal")
+	}
+
+	ctx := cmd.Context()
+	current := stage
+
+	for {
+		config, err := loadStageConfig(ctx, current)
+
+		if err != nil {
+			return errors.Wrap(err, "failed to load config")
+		}
+
+		st, err := store.GetStore(ctx, store.StoreConfig{
+			Provider: config.Provider,
+			Region:   config.Region,
+			FilePath: config.Filepath,
+			Session:  config.Session,
+		})
+
+		if err != nil {
+			return errors.Wrap(err, "failed to instantiate store")
+		}
+
+		s := &uiSession{ctx: ctx, config: config, st: st}
+
+		err = s.browse()
+
+		if err == errQuit {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if current, err = selectStage(config); err != nil {
+			if err == errQuit {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+type uiSession struct {
+	ctx    context.Context
+	config *c.Config
+	st     store.Store
+}
+
+// browse lists keys of the stage until the stage is changed or the ui is quit
+func (s *uiSession) browse() error {
+	for {
+		items, stored, err := s.load()
+
+		if err != nil {
+			return err
+		}
+
+		labels := []string{}
+		for _, item := range items {
+			scope := "service"
+			if item.Shared {
+				scope = "shared"
+			}
+			labels = append(labels, fmt.Sprintf("%-40s %-8s %-12s %s", item.Key, scope, item.Status, oneLine(item.Value)))
+		}
+		labels = append(labels, uiNewKey, uiChangeStage, uiQuit)
+
+		i, err := choose(fmt.Sprintf("%s (%s)", s.config.Prefix, s.config.Provider), labels)
+
+		if err != nil {
+			return err
+		}
+
+		switch labels[i] {
+		case uiNewKey:
+			err = s.create(stored)
+		case uiChangeStage:
+			return nil
+		case uiQuit:
+			return errQuit
+		default:
+			err = s.keyMenu(items[i], stored[items[i].Name])
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// load reads declared keys and everything under the prefix, keyed by name
+func (s *uiSession) load() ([]ParamDoc, map[string]store.Config, error) {
+	configs, err := s.st.GetMany(s.ctx, s.config.All)
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list params")
+	}
+
+	existing, err := s.st.GetByPath(s.ctx, s.config.Prefix)
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list params by path")
+	}
+
+	stored := map[string]store.Config{}
+	for _, p := range append(configs, existing...) {
+		stored[*p.Name] = p
+	}
+
+	items := listItems(configs, existing, s.config)
+	sort.Sort(ByName(items))
+
+	return items, stored, nil
+}
+
+func (s *uiSession) keyMenu(item ParamDoc, param store.Config) error {
+	revealed := false
+
+	// the key may have changed since the list was read
+	if item.Status != StatusMissing {
+		fresh, err := s.st.Get(s.ctx, store.ConfigInput{Name: item.Name})
+
+		if errors.Cause(err) == store.ConfigNotFoundError || err == nil && fresh == nil {
+			fmt.Fprintf(os.Stderr, "%s no longer exists\n", item.Name)
+			return nil
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return nil
+		}
+
+		param = *fresh
+	}
+
+	for {
+		actions := keyActions(item, revealed)
+
+		i, err := choose(item.Name, actions)
+
+		if err != nil {
+			return err
+		}
+
+		switch actions[i] {
+		case uiDetails:
+			err = s.details(item, param)
+		case uiReveal:
+			fmt.Println(*param.Value)
+			revealed = true
+		case uiHistory:
+			err = s.history(item, revealed)
+		case uiEdit, uiSet:
+			var written bool
+			if written, err = s.edit(s.inputFor(item), param.Value); written {
+				return err
+			}
+		case uiDelete:
+			var deleted bool
+			if deleted, err = s.delete(s.inputFor(item), param); deleted {
+				return err
+			}
+		case uiBack:
+			return nil
+		}
+
+		if err == errQuit {
+			return err
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+	}
+}
+
+// keyActions returns the actions of a key. Missing keys can only be set and
+// masked values can be revealed once.
+func keyActions(item ParamDoc, revealed bool) []string {
+	if item.Status == StatusMissing {
+		return []string{uiSet, uiBack}
+	}
+
+	actions := []string{uiDetails, uiHistory, uiEdit, uiDelete, uiBack}
+	if item.Masked && !revealed {
+		actions = append([]string{uiReveal}, actions...)
+	}
+
+	return actions
+}
+
+func (s *uiSession) details(item ParamDoc, param store.Config) error {
+	tags, err := tagsOf(s.ctx, s.st, param)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read tags")
+	}
+
+	kind, scope := "config", "service"
+	if item.Secret {
+		kind = "secret"
+	}
+	if item.Shared {
+		scope = "shared"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name\t%s\n", item.Name)
+	fmt.Fprintf(w, "Status\t%s\n", item.Status)
+	fmt.Fprintf(w, "Kind\t%s\n", kind)
+	fmt.Fprintf(w, "Scope\t%s\n", scope)
+	fmt.Fprintf(w, "Type\t%s\n", item.Type)
+	fmt.Fprintf(w, "Version\t%s\n", item.Version)
+	if len(param.VersionStages) > 0 {
+		fmt.Fprintf(w, "Stages\t%s\n", strings.Join(param.VersionStages, ", "))
+	}
+	if !param.Created.IsZero() {
+		fmt.Fprintf(w, "Created\t%s\n", param.Created.Local().Format(TimeFormat))
+	}
+	if !item.Modified.IsZero() {
+		fmt.Fprintf(w, "LastModified\t%s\n", item.Modified.Local().Format(TimeFormat))
+	}
+	if item.Description != "" {
+		fmt.Fprintf(w, "Description\t%s\n", item.Description)
+	}
+	for _, k := range sortedKeys(tags) {
+		fmt.Fprintf(w, "Tag\t%s=%s\n", k, tags[k])
+	}
+
+	return w.Flush()
+}
+
+func (s *uiSession) history(item ParamDoc, revealed bool) error {
+	h, ok := s.st.(store.HistoryReader)
+
+	if !ok {
+		return errors.Errorf("version history is not available for %s provider", s.config.Provider)
+	}
+
+	versions, err := h.GetHistory(s.ctx, item.Name)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read history")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Version\tLastModified\tStages\tValue")
+
+	for _, v := range versions {
+		value := oneLine(*v.Value)
+		if item.Secret && !revealed {
+			value = maskValue(*v.Value)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			v.Version,
+			v.Modified.Local().Format(TimeFormat),
+			strings.Join(v.VersionStages, ", "),
+			value,
+		)
+	}
+
+	return w.Flush()
+}
+
+// edit prompts for a new value and writes it once confirmed. It reports
+// whether the value was written.
+func (s *uiSession) edit(input store.ConfigInput, current *string) (bool, error) {
+	if current != nil {
+		input.Value = *current
+	}
+
+	updated, err := promptValue(input)
+
+	if err != nil {
+		return false, err
+	}
+
+	if current != nil && updated.Value == *current {
+		fmt.Println("value unchanged")
+		return false, nil
+	}
+
+	for _, d := range s.config.Configs {
+		if d.Name == input.Name && d.Value != updated.Value {
+			fmt.Fprintln(os.Stderr, "Warning: value differs from config file and is reverted by the next deploy")
+		}
+	}
+
+	ok, err := confirm(fmt.Sprintf("Write %s", input.Name), false)
+
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if err := s.st.PutMany(s.ctx, []store.ConfigInput{updated}); err != nil {
+		return false, errors.Wrap(err, "failed to write param")
+	}
+
+	recordAudit(s.ctx, s.st, s.config, audit.ActionSet, []store.ConfigInput{updated})
+
+	fmt.Printf("wrote %s\n", input.Name)
+
+	return true, nil
+}
+
+// delete removes a parameter once confirmed and reports whether it was
+// removed. Protected keys and shared keys owned by another service are
+// refused as in orphan removal, and shared keys are confirmed twice.
+func (s *uiSession) delete(input store.ConfigInput, param store.Config) (bool, error) {
+	allowed, err := deletable(s.ctx, s.st, s.config, []store.Config{param}, deleteLimit(s.config))
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(allowed) == 0 {
+		return false, errors.Errorf("refusing to delete protected %s", input.Name)
+	}
+
+	for _, d := range s.config.All {
+		if d.Name == input.Name {
+			fmt.Fprintln(os.Stderr, "Warning: key is declared in config file and is deployed again by the next deploy")
+			break
+		}
+	}
+
+	if strings.HasPrefix(input.Name, s.config.SharedPrefix) {
+		ok, err := confirm(fmt.Sprintf("%s is shared and may be used by other services. Delete it anyway", input.Key()), false)
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete %s", input.Name), false)
+
+	if err != nil || !ok {
+		return false, err
+	}
+
+	err = s.st.DeleteMany(s.ctx, []store.ConfigInput{input})
+
+	if errors.Cause(err) == store.ConfigNotFoundError {
+		fmt.Printf("%s no longer exists\n", input.Name)
+		return true, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete param")
+	}
+
+	recordAudit(s.ctx, s.st, s.config, audit.ActionDelete, []store.ConfigInput{input})
+
+	fmt.Printf("deleted %s\n", input.Name)
+
+	return true, nil
+}
+
+// create adds a key under the service or shared prefix. Keys not declared in
+// the config file are orphans until they are added to it.
+func (s *uiSession) create(stored map[string]store.Config) error {
+	p := promptui.Prompt{
+		Label:  "Key",
+		Stdout: os.Stderr,
+		Validate: func(input string) error {
+			if input == "" || strings.ContainsAny(input, "/ ") {
+				return errors.New("key must not be empty or contain / or spaces")
+			}
+			return nil
+		},
+	}
+
+	key, err := p.Run()
+
+	if err != nil {
+		return promptError(err)
+	}
+
+	scopes := []string{"service", "shared"}
+	scope, err := choose("Scope", scopes)
+
+	if err != nil {
+		return err
+	}
+
+	kinds := []string{"config", "secret"}
+	kind, err := choose("Kind", kinds)
+
+	if err != nil {
+		return err
+	}
+
+	input := store.ConfigInput{
+		Name:    s.config.Prefix + key,
+		Secret:  kinds[kind] == "secret",
+		Shared:  scopes[scope] == "shared",
+		Options: s.config.Options,
+	}
+
+	if input.Shared {
+		input.Name = s.config.SharedPrefix + key
+	}
+
+	for _, d := range s.config.All {
+		if d.Name == input.Name {
+			input = d
+			break
+		}
+	}
+
+	var current *string
+	if existing, ok := stored[input.Name]; ok {
+		fmt.Fprintf(os.Stderr, "%s already exists\n", input.Name)
+		current = existing.Value
+	}
+
+	written, err := s.edit(input, current)
+
+	if err == nil && written && current == nil {
+		fmt.Println(promptui.Styler(promptui.FGFaint)("declare the key in config file, otherwise it is an orphan"))
+	}
+
+	if err != nil && err != errQuit {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return nil
+	}
+
+	return err
+}
+
+// inputFor returns the declared input of an item, or an input with service
+// options for orphans
+func (s *uiSession) inputFor(item ParamDoc) store.ConfigInput {
+	for _, d := range s.config.All {
+		if d.Name == item.Name {
+			return d
+		}
+	}
+
+	return store.ConfigInput{
+		Name:        item.Name,
+		Secret:      item.Secret,
+		Description: item.Description,
+		Options:     s.config.Options,
+	}
+}
+
+// promptValue asks for the value of a config. Secrets are masked, configs are
+// edited in place and :edit opens $EDITOR for both.
+func promptValue(input store.ConfigInput) (store.ConfigInput, error) {
+	if input.Secret {
+		updated, err := promptConfig(input)
+		if err != nil {
+			return input, promptError(errors.Cause(err))
+		}
+		return updated, nil
+	}
+
+	p := promptui.Prompt{
+		Label:     input.Key(),
+		Default:   input.Value,
+		AllowEdit: true,
+		Stdout:    os.Stderr,
+	}
+
+	result, err := p.Run()
+
+	if err != nil {
+		return input, promptError(err)
+	}
+
+	if result == ":edit" {
+		return withValue(input, editValue)
+	}
+
+	input.Value = result
+
+	return input, nil
+}
+
+func selectStage(config *c.Config) (string, error) {
+	stages := append([]string{}, config.DeclaredStages...)
+
+	found := false
+	for _, s := range stages {
+		found = found || s == config.Stage
+	}
+	if !found && config.Stage != "" {
+		stages = append(stages, config.Stage)
+	}
+
+	sort.Strings(stages)
+	stages = append(stages, uiOtherStage)
+
+	i, err := choose("Stage", stages)
+
+	if err != nil {
+		return "", err
+	}
+
+	if stages[i] != uiOtherStage {
+		return stages[i], nil
+	}
+
+	p := promptui.Prompt{Label: "Stage", Stdout: os.Stderr}
+	result, err := p.Run()
+
+	if err != nil {
+		return "", promptError(err)
+	}
+
+	return result, nil
+}
+
+// choose shows a searchable list and returns the index of the selected item
+func choose(label string, items []string) (int, error) {
+	s := promptui.Select{
+		Label:  label,
+		Items:  items,
+		Size:   15,
+		Stdout: os.Stderr,
+		Searcher: func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(items[index]), strings.ToLower(input))
+		},
+	}
+
+	i, _, err := s.Run()
+
+	if err != nil {
+		return 0, promptError(err)
+	}
+
+	return i, nil
+}
+
+// promptError turns ctrl-c and ctrl-d into quitting the ui
+func promptError(err error) error {
+	if err == promptui.ErrInterrupt || err == promptui.ErrEOF {
+		return errQuit
+	}
+
+	return err
+}
+
+func oneLine(value string) string {
+	r := []rune(strings.SplitN(value, "\n", 2)[0])
+
+	if len(r) > 60 {
+		return string(r[:57]) + "..."
+	}
+
+	return string(r)
+}
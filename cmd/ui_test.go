@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+)
+
+func TestKeyActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     ParamDoc
+		revealed bool
+		want     []string
+	}{
+		{name: "missing", item: ParamDoc{Status: StatusMissing}, want: []string{uiSet, uiBack}},
+		{name: "config", item: ParamDoc{Status: StatusPresent}, want: []string{uiDetails, uiHistory, uiEdit, uiDelete, uiBack}},
+		{name: "masked secret", item: ParamDoc{Status: StatusPresent, Masked: true}, want: []string{uiReveal, uiDetails, uiHistory, uiEdit, uiDelete, uiBack}},
+		{name: "revealed secret", item: ParamDoc{Status: StatusPresent, Masked: true}, revealed: true, want: []string{uiDetails, uiHistory, uiEdit, uiDelete, uiBack}},
+		{name: "orphan", item: ParamDoc{Status: StatusOrphan}, want: []string{uiDetails, uiHistory, uiEdit, uiDelete, uiBack}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keyActions(tt.item, tt.revealed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keyActions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyMenuOfRemovedKey(t *testing.T) {
+	s := &uiSession{ctx: context.Background(), config: &c.Config{}, st: &memStore{}}
+
+	// the menu is not shown for a key removed since the list was read
+	if err := s.keyMenu(ParamDoc{Name: "/dev/api/GONE", Status: StatusPresent}, param("/dev/api/GONE", "v")); err != nil {
+		t.Errorf("keyMenu() error = %v", err)
+	}
+}
+
+func TestUiDeleteRefused(t *testing.T) {
+	config := &c.Config{
+		Service:      "api",
+		Prefix:       "/dev/api/",
+		SharedPrefix: "/dev/shared/",
+		Orphans:      c.Orphans{MaxDelete: 10, Protected: []string{"LOCKED"}},
+	}
+
+	tagged := func(p store.Config, tags map[string]string) store.Config {
+		p.Tags = tags
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		param   store.Config
+		wantErr string
+	}{
+		{
+			name:    "protected in config file",
+			param:   tagged(param("/dev/api/LOCKED", "v"), map[string]string{}),
+			wantErr: "protected",
+		},
+		{
+			name:    "protected by tag",
+			param:   tagged(param("/dev/api/KEY", "v"), map[string]string{protectedTag: "true"}),
+			wantErr: "protected",
+		},
+		{
+			name:    "shared owned by another service",
+			param:   tagged(param("/dev/shared/REGION", "v"), map[string]string{c.OwnerTag: "billing"}),
+			wantErr: "owned by billing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &memStore{params: []store.Config{tt.param}}
+			s := &uiSession{ctx: context.Background(), config: config, st: st}
+
+			deleted, err := s.delete(store.ConfigInput{Name: *tt.param.Name}, tt.param)
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("delete() error = %v, want %q", err, tt.wantErr)
+			}
+
+			if deleted || len(st.deleted) > 0 {
+				t.Errorf("deleted %v", st.deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+
+	watchCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Regenerates files when configurations change",
+		Long: `Polls the store and the config file, and rewrites the files under generate
+whenever a value or the config file changes. Runs until interrupted.
+
+Changes are found by polling every --interval. Events of the store, such as
+EventBridge notifications of parameter changes, are not used. When the config
+file can not be read or is invalid, the previous config is kept and reading it
+is retried.`,
+		RunE: watch,
+	}
+)
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 10*time.Second, "how often to poll the store")
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+func watch(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	return watchConfigs(ctx, watchInterval, func(config *c.Config, _ []store.Config) error {
+		if len(config.Generate) == 0 {
+			fmt.Fprintln(os.Stderr, "nothing to generate")
+			return nil
+		}
+
+		printGenerated(generateFiles(ctx, config))
+		return nil
+	})
+}
+
+// watchConfigs calls onChange with the config and deployed values on start and
+// whenever a value or the config file changes, until ctx is done. Failing to
+// find or reload the config, or to read the store, is reported and retried
+// with backoff, keeping the previous config.
+func watchConfigs(ctx context.Context, interval time.Duration, onChange func(config *c.Config, configs []store.Config) error) error {
+	path := c.ResolvePath(pathToConfig)
+
+	var config *c.Config
+	var st store.Store
+	var modified time.Time
+	var last string
+	failures := 0
+
+	for {
+		failed := false
+
+		fi, err := os.Stat(path)
+
+		switch {
+		case err != nil && config == nil:
+			return err
+		case err != nil:
+			// editors replace the file on save, so it may be missing for a moment
+			failed = true
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		case config == nil || fi.ModTime() != modified:
+			cfg, s, err := loadWatched(ctx)
+
+			switch {
+			case err != nil && config == nil:
+				return err
+			case err != nil:
+				failed = true
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			default:
+				modified = fi.ModTime()
+				config, st, last = cfg, s, ""
+			}
+		}
+
+		configs, err := st.GetMany(ctx, config.All)
+
+		if err != nil && ctx.Err() == nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "Error: failed to read params: %s\n", err)
+		}
+
+		if f := fingerprint(configs); err == nil && f != last {
+			last = f
+			if err := onChange(config, configs); err != nil {
+				return err
+			}
+		}
+
+		wait := interval
+		if failed {
+			failures++
+			wait = retryDelay(interval, failures)
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryDelay doubles from a second on each consecutive failure, up to interval
+func retryDelay(interval time.Duration, failures int) time.Duration {
+	delay := time.Second
+	for i := 1; i < failures && delay < interval; i++ {
+		delay *= 2
+	}
+
+	if delay > interval {
+		return interval
+	}
+	return delay
+}
+
+func loadWatched(ctx context.Context) (*c.Config, store.Store, error) {
+	config, err := loadConfig(ctx)
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load config")
+	}
+
+	st, err := store.GetStore(ctx, store.StoreConfig{
+		Provider: config.Provider,
+		Region:   config.Region,
+		FilePath: config.Filepath,
+		Session:  config.Session,
+	})
+
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to instantiate store")
+	}
+
+	return config, st, nil
+}
+
+// fingerprint changes when any name or value changes
+func fingerprint(configs []store.Config) string {
+	sorted := make([]store.Config, len(configs))
+	copy(sorted, configs)
+	sort.Slice(sorted, func(i, j int) bool { return *sorted[i].Name < *sorted[j].Name })
+
+	h := sha256.New()
+	for _, p := range sorted {
+		fmt.Fprintf(h, "%s\x00%s\x00", *p.Name, *p.Value)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
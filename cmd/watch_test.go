@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	c "github.com/adikari/safebox/v2/config"
+	"github.com/adikari/safebox/v2/store"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{interval: 10 * time.Second, failures: 1, want: time.Second},
+		{interval: 10 * time.Second, failures: 2, want: 2 * time.Second},
+		{interval: 10 * time.Second, failures: 4, want: 8 * time.Second},
+		{interval: 10 * time.Second, failures: 5, want: 10 * time.Second},
+		{interval: 10 * time.Second, failures: 100, want: 10 * time.Second},
+		{interval: 500 * time.Millisecond, failures: 1, want: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(tt.interval, tt.failures); got != tt.want {
+			t.Errorf("retryDelay(%v, %d) = %v, want %v", tt.interval, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := []store.Config{param("/dev/api/A", "1"), param("/dev/api/B", "2")}
+
+	tests := []struct {
+		name    string
+		configs []store.Config
+		changed bool
+	}{
+		{name: "same order", configs: []store.Config{param("/dev/api/A", "1"), param("/dev/api/B", "2")}},
+		{name: "other order", configs: []store.Config{param("/dev/api/B", "2"), param("/dev/api/A", "1")}},
+		{name: "value changed", configs: []store.Config{param("/dev/api/A", "1"), param("/dev/api/B", "3")}, changed: true},
+		{name: "key removed", configs: []store.Config{param("/dev/api/A", "1")}, changed: true},
+		{name: "values moved", configs: []store.Config{param("/dev/api/A", "12"), param("/dev/api/B", "")}, changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if changed := fingerprint(tt.configs) != fingerprint(base); changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestWatchConfigsWhileConfigFileIsReplaced(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "safebox.yml")
+	content := []byte("service: api\nprovider: gpg\ndb_dir: " + dir + "\nconfig:\n  defaults:\n    HOST: h\n")
+
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pathToConfig = path
+	defer func() { pathToConfig = "" }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tmp := path + ".tmp"
+	replaced := make(chan error, 1)
+	calls := 0
+
+	err := watchConfigs(ctx, 10*time.Millisecond, func(config *c.Config, _ []store.Config) error {
+		if calls++; calls > 1 {
+			return nil
+		}
+
+		// save the file as editors do, by renaming a new file over the removed one
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			err := ioutil.WriteFile(tmp, content, 0644)
+			if err == nil {
+				err = os.Rename(tmp, path)
+			}
+			replaced <- err
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("watchConfigs() error = %v", err)
+	}
+
+	if err := <-replaced; err != nil {
+		t.Fatal(err)
+	}
+}
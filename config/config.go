@@ -2,18 +2,19 @@ package config
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/adikari/safebox/v2/aws"
 	"github.com/adikari/safebox/v2/store"
 	"github.com/adikari/safebox/v2/util"
-	a "github.com/aws/aws-sdk-go/aws"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
@@ -25,42 +26,149 @@ type rawConfig struct {
 	Generate             []Generate `yaml:"generate"`
 	Config               map[string]map[string]string
 	Secret               map[string]map[string]string
-	CloudformationStacks []string `yaml:"cloudformation-stacks"`
-	Region               string   `yaml:"region"`
-	DBDir                string   `yaml:"db_dir"`
+	Types                map[string]string `yaml:"types"`
+	Options              rawOptions        `yaml:"options"`
+	Orphans              Orphans           `yaml:"orphans"`
+	SharedConflict       string            `yaml:"shared-conflict"`
+	Audit                Audit             `yaml:"audit"`
+	CloudformationStacks []string          `yaml:"cloudformation-stacks"`
+	Region               string            `yaml:"region"`
+	DBDir                string            `yaml:"db_dir"`
+	AwsAuth              `yaml:",inline"`
+	EndpointUrl          string            `yaml:"endpoint-url"`
+	Endpoints            map[string]string `yaml:"endpoints"`
+	Stages               map[string]Stage  `yaml:"stages"`
+}
+
+// AwsAuth selects the aws profile and role to use
+type AwsAuth struct {
+	Profile    string
+	RoleArn    string `yaml:"role-arn"`
+	ExternalId string `yaml:"external-id"`
+	MfaSerial  string `yaml:"mfa-serial"`
+}
+
+// Stage overrides region and aws auth for a stage, eg. to assume a role in
+// the prod account
+type Stage struct {
+	Region  string
+	AwsAuth `yaml:",inline"`
+}
+
+type rawOptions struct {
+	store.ConfigOptions `yaml:",inline"`
+	Keys                map[string]store.ConfigOptions `yaml:"keys"`
 }
 
 type Config struct {
-	Provider string
-	Service  string
-	Stage    string
-	Prefix   string
-	Generate []Generate
-	Region   string
-	All      []store.ConfigInput
-	Configs  []store.ConfigInput
-	Secrets  []store.ConfigInput
-	Stacks   []string
-	Filepath string
+	Provider     string
+	Service      string
+	Stage        string
+	Prefix       string
+	SharedPrefix string
+	Generate     []Generate
+	Region       string
+	All          []store.ConfigInput
+	Configs      []store.ConfigInput
+	Secrets      []store.ConfigInput
+	Stacks       []string
+	Filepath     string
+	Types        map[string]string
+	// Options are service level options, applied to undeclared parameters
+	Options store.ConfigOptions
+	Orphans Orphans
+	// SharedConflict is warn or fail, when a shared config owned by another
+	// service is declared with a different value
+	SharedConflict string
+	Audit          Audit
+	// Session is the aws profile and role of the stage
+	Session aws.SessionConfig
+	// DeclaredStages are all stages named in the config file
+	DeclaredStages []string
+}
+
+// Audit configures where audit events of mutating commands are written
+type Audit struct {
+	// Sink is a jsonl file path, s3://bucket/prefix or http(s) webhook url
+	Sink string
+	// HashKey is the hmac key of value hashes. Values are not hashed without it
+	HashKey string `yaml:"hash-key"`
+}
+
+// AuditHashKeyEnv overrides audit.hash-key of config file
+const AuditHashKeyEnv = "SAFEBOX_AUDIT_HASH_KEY"
+
+// Orphans configures removal of deployed parameters no longer declared
+type Orphans struct {
+	// Protected keys are never removed
+	Protected []string
+	// MaxDelete is the most orphans removed at once
+	MaxDelete int `yaml:"max-delete"`
+	// IncludeShared removes shared parameters owned by the service
+	IncludeShared bool `yaml:"include-shared"`
+}
+
+const defaultMaxDelete = 10
+
+const (
+	// OwnerTag is the service that first deployed a shared parameter
+	OwnerTag = "safebox:owner"
+	// UsedByTagPrefix is followed by the name of a service using a shared parameter
+	UsedByTagPrefix = "safebox:used-by:"
+
+	SharedConflictWarn = "warn"
+	SharedConflictFail = "fail"
+)
+
+func UsedByTag(service string) string {
+	return UsedByTagPrefix + service
 }
 
 type Generate struct {
-	Type string
-	Path string
+	Type          string
+	Path          string
+	Template      string
+	Nested        bool
+	ExpandJson    bool   `yaml:"expand-json"`
+	Package       string `yaml:"package"`
+	PreferService bool   `yaml:"prefer-service"`
 }
 
 type LoadConfigInput struct {
 	Path  string
 	Stage string
+	// Auth overrides aws auth of the config file
+	Auth AwsAuth
+	// EndpointUrl overrides endpoint of all aws services
+	EndpointUrl string
 }
 
 var defaultConfigPaths = []string{"safebox.yml", "safebox.yaml"}
 
-func Load(param LoadConfigInput) (*Config, error) {
+// ResolvePath returns the config file loaded for path, which may be empty to
+// use the default paths
+func ResolvePath(path string) string {
+	if path != "" {
+		return path
+	}
+
+	for _, c := range defaultConfigPaths {
+		if _, err := os.Stat(c); err == nil {
+			return c
+		}
+	}
+
+	return defaultConfigPaths[0]
+}
+
+// ValueTypes are the types a key can be declared as under `types`
+var ValueTypes = []string{"string", "int", "float", "bool"}
+
+func Load(ctx context.Context, param LoadConfigInput) (*Config, error) {
 	yamlFile, err := readConfigFile(param.Path)
 
 	if err != nil {
-		return nil, fmt.Errorf(err.Error())
+		return nil, errors.New(err.Error())
 	}
 
 	rc := rawConfig{}
@@ -68,8 +176,7 @@ func Load(param LoadConfigInput) (*Config, error) {
 	err = yaml.Unmarshal(yamlFile, &rc)
 
 	if err != nil {
-		fmt.Printf("%v", err)
-		return nil, fmt.Errorf("could not parse safebox config file %s", param.Path)
+		return nil, errors.Wrap(err, fmt.Sprintf("could not parse safebox config file %s", ResolvePath(param.Path)))
 	}
 
 	err = validateConfig(rc)
@@ -83,6 +190,18 @@ func Load(param LoadConfigInput) (*Config, error) {
 		Stage:    param.Stage,
 		Provider: rc.Provider,
 		Generate: rc.Generate,
+		Types:    rc.Types,
+		Orphans:  rc.Orphans,
+
+		SharedConflict: rc.SharedConflict,
+	}
+
+	if c.SharedConflict == "" {
+		c.SharedConflict = SharedConflictWarn
+	}
+
+	if c.Orphans.MaxDelete == 0 {
+		c.Orphans.MaxDelete = defaultMaxDelete
 	}
 
 	if c.Provider == "" {
@@ -93,7 +212,10 @@ func Load(param LoadConfigInput) (*Config, error) {
 		c.Filepath = getFilePath(c, rc)
 	}
 
-	variables, err := loadVariables(&c, rc)
+	c.Session = sessionConfig(rc, param)
+	c.DeclaredStages = declaredStages(rc)
+
+	variables, err := loadVariables(ctx, &c, rc)
 
 	if c.Region == "" {
 		c.Region = "local"
@@ -108,6 +230,18 @@ func Load(param LoadConfigInput) (*Config, error) {
 		return nil, errors.Wrap(err, "failed to interpolate prefix")
 	}
 
+	c.SharedPrefix = formatSharedPath(param.Stage, "")
+
+	c.Audit.Sink, err = Interpolate(rc.Audit.Sink, variables)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to interpolate audit.sink")
+	}
+
+	c.Audit.HashKey = rc.Audit.HashKey
+	if key := os.Getenv(AuditHashKeyEnv); key != "" {
+		c.Audit.HashKey = key
+	}
+
 	for key, value := range rc.Config["defaults"] {
 		val, err := Interpolate(value, variables)
 
@@ -133,6 +267,7 @@ func Load(param LoadConfigInput) (*Config, error) {
 			Name:   formatSharedPath(param.Stage, key),
 			Value:  val,
 			Secret: false,
+			Shared: true,
 		})
 	}
 
@@ -159,14 +294,118 @@ func Load(param LoadConfigInput) (*Config, error) {
 			Name:        formatSharedPath(param.Stage, key),
 			Description: value,
 			Secret:      true,
+			Shared:      true,
 		})
 	}
 
+	if err := applyOptions(&c, rc, variables); err != nil {
+		return nil, errors.Wrap(err, "invalid options")
+	}
+
 	c.All = append(c.Secrets, c.Configs...)
 
 	return &c, nil
 }
 
+// applyOptions sets options of each parameter from service level options,
+// overridden by options of the key
+func applyOptions(c *Config, rc rawConfig, variables map[string]string) error {
+	defaults := store.ConfigOptions{Tags: map[string]string{}}
+
+	// default tags are only added along with declared tags, so that deploying
+	// without tags does not require permission to tag parameters
+	if declaresTags(rc.Options) {
+		defaults.Tags["service"] = c.Service
+		defaults.Tags["managed-by"] = "safebox"
+
+		if c.Stage != "" {
+			defaults.Tags["stage"] = c.Stage
+		}
+	}
+
+	service, err := interpolateOptions(defaults.Merge(rc.Options.ConfigOptions), variables)
+
+	if err != nil {
+		return err
+	}
+
+	c.Options = service
+
+	apply := func(inputs []store.ConfigInput) error {
+		for i, input := range inputs {
+			opts, err := interpolateOptions(service.Merge(rc.Options.Keys[input.Key()]), variables)
+
+			if err != nil {
+				return errors.Wrap(err, input.Key())
+			}
+
+			if err := opts.Validate(input.Secret); err != nil {
+				return errors.Wrap(err, input.Key())
+			}
+
+			// shared parameters record their owner and every service using them
+			if input.Shared {
+				delete(opts.Tags, "service")
+				opts.Tags[OwnerTag] = c.Service
+				opts.Tags[UsedByTag(c.Service)] = "true"
+			}
+
+			inputs[i].Options = opts
+		}
+
+		return nil
+	}
+
+	if err := apply(c.Configs); err != nil {
+		return err
+	}
+
+	return apply(c.Secrets)
+}
+
+func declaresTags(o rawOptions) bool {
+	if len(o.Tags) > 0 {
+		return true
+	}
+
+	for _, k := range o.Keys {
+		if len(k.Tags) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func interpolateOptions(opts store.ConfigOptions, variables map[string]string) (store.ConfigOptions, error) {
+	var err error
+
+	for k, v := range opts.Tags {
+		if opts.Tags[k], err = Interpolate(v, variables); err != nil {
+			return opts, errors.Wrap(err, fmt.Sprintf("failed to interpolate tags.%s", k))
+		}
+	}
+
+	if opts.KmsKeyId, err = Interpolate(opts.KmsKeyId, variables); err != nil {
+		return opts, errors.Wrap(err, "failed to interpolate kms-key-id")
+	}
+
+	if opts.RotationLambdaArn, err = Interpolate(opts.RotationLambdaArn, variables); err != nil {
+		return opts, errors.Wrap(err, "failed to interpolate rotation-lambda-arn")
+	}
+
+	return opts, nil
+}
+
+// TypeOf returns the declared type of key. Defaults to string.
+func (c *Config) TypeOf(key string) string {
+	if t, ok := c.Types[key]; ok {
+		return t
+	}
+
+	return "string"
+}
+
 func formatSharedPath(stage string, key string) string {
 	if stage != "" {
 		return fmt.Sprintf("/%s/shared/%s", stage, key)
@@ -191,6 +430,32 @@ func getPrefix(stage string, service string, defaultPrefix string) string {
 	return fmt.Sprintf("/%s/", service)
 }
 
+// declaredStages returns stages with their own config, secrets or aws auth
+func declaredStages(rc rawConfig) []string {
+	seen := map[string]bool{"defaults": true, "shared": true}
+	stages := []string{}
+
+	add := func(stage string) {
+		if !seen[stage] {
+			seen[stage] = true
+			stages = append(stages, stage)
+		}
+	}
+
+	for stage := range rc.Config {
+		add(stage)
+	}
+	for stage := range rc.Secret {
+		add(stage)
+	}
+	for stage := range rc.Stages {
+		add(stage)
+	}
+
+	sort.Strings(stages)
+	return stages
+}
+
 func validateConfig(rc rawConfig) error {
 	if rc.Service == "" {
 		return fmt.Errorf("'service' is missing")
@@ -200,21 +465,98 @@ func validateConfig(rc rawConfig) error {
 		return fmt.Errorf("'provider' is missing")
 	}
 
+	if rc.MfaSerial != "" && rc.RoleArn == "" {
+		return fmt.Errorf("'mfa-serial' requires 'role-arn'")
+	}
+
+	for name, s := range rc.Stages {
+		if s.MfaSerial != "" && s.RoleArn == "" && rc.RoleArn == "" {
+			return fmt.Errorf("'stages.%s.mfa-serial' requires 'role-arn'", name)
+		}
+	}
+
+	if rc.SharedConflict != "" && rc.SharedConflict != SharedConflictWarn && rc.SharedConflict != SharedConflictFail {
+		return fmt.Errorf("'shared-conflict' must be warn or fail")
+	}
+
+	for key, t := range rc.Types {
+		valid := false
+		for _, v := range ValueTypes {
+			if t == v {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return fmt.Errorf("'types.%s' must be one of %s", key, strings.Join(ValueTypes, ", "))
+		}
+	}
+
 	return nil
 }
 
+// sessionConfig merges aws auth of the config file, the stage and the cli, in
+// that order
+func sessionConfig(rc rawConfig, param LoadConfigInput) aws.SessionConfig {
+	stage := rc.Stages[param.Stage]
+
+	s := aws.SessionConfig{
+		Region:      rc.Region,
+		Profile:     rc.Profile,
+		RoleArn:     rc.RoleArn,
+		ExternalId:  rc.ExternalId,
+		MfaSerial:   rc.MfaSerial,
+		EndpointUrl: rc.EndpointUrl,
+		Endpoints:   rc.Endpoints,
+	}
+
+	if param.EndpointUrl != "" {
+		s.EndpointUrl = param.EndpointUrl
+	}
+
+	for _, auth := range []AwsAuth{stage.AwsAuth, param.Auth} {
+		if auth.Profile != "" {
+			s.Profile = auth.Profile
+		}
+		if auth.RoleArn != "" {
+			s.RoleArn = auth.RoleArn
+			s.ExternalId = auth.ExternalId
+			s.MfaSerial = auth.MfaSerial
+		}
+		if auth.ExternalId != "" {
+			s.ExternalId = auth.ExternalId
+		}
+		if auth.MfaSerial != "" {
+			s.MfaSerial = auth.MfaSerial
+		}
+	}
+
+	if stage.Region != "" {
+		s.Region = stage.Region
+	}
+
+	return s
+}
+
 // loadVariables for interpolation
 // TODO: in future as we support more stores, this many need to be refactored to handled each
-func loadVariables(c *Config, rc rawConfig) (map[string]string, error) {
+func loadVariables(ctx context.Context, c *Config, rc rawConfig) (map[string]string, error) {
 	if !util.IsAwsProvider(c.Provider) {
 		return map[string]string{}, nil
 	}
 
-	session := aws.NewSession(a.Config{Region: &rc.Region})
+	session, err := aws.NewSession(ctx, c.Session)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create aws session")
+	}
+
 	st := aws.NewSts(session)
-	c.Region = *session.Config.Region
+	c.Region = session.Region()
+	c.Session.Region = c.Region
 
-	id, err := st.GetCallerIdentity()
+	id, err := st.GetCallerIdentity(ctx)
 
 	if err != nil {
 		return nil, errors.New("Failed to login to AWS")
@@ -238,7 +580,7 @@ func loadVariables(c *Config, rc rawConfig) (map[string]string, error) {
 	// add cloudformation outputs to variables available for interpolation
 	if len(c.Stacks) > 0 {
 		cf := aws.NewCloudformation(session)
-		outputs, err := cf.GetOutputs(c.Stacks)
+		outputs, err := cf.GetOutputs(ctx, c.Stacks)
 
 		if err != nil {
 			return nil, err
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -42,7 +43,7 @@ func NewGpgStore(config GpgStoreOptions) (*GpgStore, error) {
 	return store, nil
 }
 
-func (s *GpgStore) PutMany(input []ConfigInput) error {
+func (s *GpgStore) PutMany(_ context.Context, input []ConfigInput) error {
 	updates := []Config{}
 
 	for _, c := range input {
@@ -64,6 +65,7 @@ func (s *GpgStore) PutMany(input []ConfigInput) error {
 			Type:     t,
 			Created:  now,
 			Modified: now,
+			Tags:     c.Options.Tags,
 		})
 	}
 
@@ -73,6 +75,8 @@ func (s *GpgStore) PutMany(input []ConfigInput) error {
 		if found != nil {
 			v, _ := strconv.Atoi(e.Version)
 			found.Version = strconv.Itoa(v + 1)
+			found.Created = e.Created
+			found.Tags = mergeTags(e.Tags, found.Tags)
 			updates[i] = *found
 		} else {
 			updates = append(updates, e)
@@ -82,11 +86,37 @@ func (s *GpgStore) PutMany(input []ConfigInput) error {
 	return s.write(updates)
 }
 
-func (s *GpgStore) Put(input ConfigInput) error {
-	return s.PutMany([]ConfigInput{input})
+func (s *GpgStore) PutTags(_ context.Context, name string, tags map[string]string) error {
+	existing, err := s.read()
+
+	if err != nil {
+		return err
+	}
+
+	found, i := find(name, existing)
+
+	if found == nil {
+		return ConfigNotFoundError
+	}
+
+	if found.Tags == nil {
+		found.Tags = map[string]string{}
+	}
+
+	for k, v := range tags {
+		found.Tags[k] = v
+	}
+
+	existing[i] = *found
+
+	return s.write(existing)
+}
+
+func (s *GpgStore) Put(ctx context.Context, input ConfigInput) error {
+	return s.PutMany(ctx, []ConfigInput{input})
 }
 
-func (s *GpgStore) DeleteMany(input []ConfigInput) error {
+func (s *GpgStore) DeleteMany(_ context.Context, input []ConfigInput) error {
 	existing, _ := s.read()
 	updates := []Config{}
 
@@ -111,7 +141,7 @@ func (s *GpgStore) DeleteMany(input []ConfigInput) error {
 	return nil
 }
 
-func (s *GpgStore) GetMany(input []ConfigInput) ([]Config, error) {
+func (s *GpgStore) GetMany(_ context.Context, input []ConfigInput) ([]Config, error) {
 	if len(input) <= 0 {
 		return []Config{}, nil
 	}
@@ -133,15 +163,15 @@ func (s *GpgStore) GetMany(input []ConfigInput) ([]Config, error) {
 	return configs, nil
 }
 
-func (s *GpgStore) Get(input ConfigInput) (*Config, error) {
-	if configs, _ := s.GetMany([]ConfigInput{input}); configs != nil && len(configs) > 0 {
+func (s *GpgStore) Get(ctx context.Context, input ConfigInput) (*Config, error) {
+	if configs, _ := s.GetMany(ctx, []ConfigInput{input}); configs != nil && len(configs) > 0 {
 		return &configs[0], nil
 	}
 
 	return nil, nil
 }
 
-func (s *GpgStore) GetByPath(path string) ([]Config, error) {
+func (s *GpgStore) GetByPath(_ context.Context, path string) ([]Config, error) {
 	existing, _ := s.read()
 	result := []Config{}
 
@@ -201,6 +231,22 @@ func find(id string, all []Config) (*Config, int) {
 	return nil, -1
 }
 
+func mergeTags(existing map[string]string, tags map[string]string) map[string]string {
+	if existing == nil && tags == nil {
+		return nil
+	}
+
+	result := map[string]string{}
+	for k, v := range existing {
+		result[k] = v
+	}
+	for k, v := range tags {
+		result[k] = v
+	}
+
+	return result
+}
+
 func stat(path string) (fi os.FileInfo, err error) {
 	if fi, err = os.Stat(path); os.IsNotExist(err) {
 		fi, err = os.Stat(path)
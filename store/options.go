@@ -0,0 +1,183 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ConfigOptions are provider specific settings of a parameter. Options not
+// supported by a provider are ignored.
+type ConfigOptions struct {
+	KmsKeyId       string            `yaml:"kms-key-id"`
+	Tags           map[string]string `yaml:"tags"`
+	Tier           string            `yaml:"tier"`
+	AllowedPattern string            `yaml:"allowed-pattern"`
+	Type           string            `yaml:"type"`
+	DataType       string            `yaml:"data-type"`
+	// Expiration is a timestamp after which ssm deletes the parameter
+	Expiration string `yaml:"expiration"`
+	// ExpirationNotification is the number of days before expiration to notify
+	ExpirationNotification int `yaml:"expiration-notification"`
+	// NoChangeNotification is the number of days without change to notify after
+	NoChangeNotification int `yaml:"no-change-notification"`
+	// RecoveryWindow is the number of days a deleted secret can be restored.
+	// Secrets are deleted without recovery when not set.
+	RecoveryWindow int `yaml:"recovery-window"`
+	// Binary secrets are provided base64 encoded
+	Binary            bool   `yaml:"binary"`
+	RotationLambdaArn string `yaml:"rotation-lambda-arn"`
+	// RotationSchedule is a rate or cron expression. Eg. rate(30 days)
+	RotationSchedule string `yaml:"rotation-schedule"`
+}
+
+var (
+	SsmTiers     = []string{"Standard", "Advanced", "Intelligent-Tiering"}
+	SsmTypes     = []string{"String", "StringList"}
+	SsmDataTypes = []string{"text", "aws:ec2:image"}
+)
+
+// Merge returns o overridden by non empty values of other. Tags are merged.
+func (o ConfigOptions) Merge(other ConfigOptions) ConfigOptions {
+	result := o
+	result.Tags = map[string]string{}
+
+	for k, v := range o.Tags {
+		result.Tags[k] = v
+	}
+	for k, v := range other.Tags {
+		result.Tags[k] = v
+	}
+
+	if other.KmsKeyId != "" {
+		result.KmsKeyId = other.KmsKeyId
+	}
+	if other.Tier != "" {
+		result.Tier = other.Tier
+	}
+	if other.AllowedPattern != "" {
+		result.AllowedPattern = other.AllowedPattern
+	}
+	if other.Type != "" {
+		result.Type = other.Type
+	}
+	if other.DataType != "" {
+		result.DataType = other.DataType
+	}
+	if other.Expiration != "" {
+		result.Expiration = other.Expiration
+	}
+	if other.ExpirationNotification != 0 {
+		result.ExpirationNotification = other.ExpirationNotification
+	}
+	if other.NoChangeNotification != 0 {
+		result.NoChangeNotification = other.NoChangeNotification
+	}
+	if other.RecoveryWindow != 0 {
+		result.RecoveryWindow = other.RecoveryWindow
+	}
+	if other.Binary {
+		result.Binary = other.Binary
+	}
+	if other.RotationLambdaArn != "" {
+		result.RotationLambdaArn = other.RotationLambdaArn
+	}
+	if other.RotationSchedule != "" {
+		result.RotationSchedule = other.RotationSchedule
+	}
+
+	return result
+}
+
+func (o ConfigOptions) Validate(secret bool) error {
+	if err := oneOf("tier", o.Tier, SsmTiers); err != nil {
+		return err
+	}
+
+	if err := oneOf("type", o.Type, SsmTypes); err != nil {
+		return err
+	}
+
+	if err := oneOf("data-type", o.DataType, SsmDataTypes); err != nil {
+		return err
+	}
+
+	if secret && o.Type == "StringList" {
+		return fmt.Errorf("'type' StringList is not supported for secrets")
+	}
+
+	if secret && o.DataType == "aws:ec2:image" {
+		return fmt.Errorf("'data-type' aws:ec2:image is not supported for secrets")
+	}
+
+	if o.RecoveryWindow != 0 && (o.RecoveryWindow < 7 || o.RecoveryWindow > 30) {
+		return fmt.Errorf("'recovery-window' must be between 7 and 30 days")
+	}
+
+	if o.RotationSchedule != "" && o.RotationLambdaArn == "" {
+		return fmt.Errorf("'rotation-schedule' requires 'rotation-lambda-arn'")
+	}
+
+	return nil
+}
+
+type ssmPolicy struct {
+	Type       string            `json:"Type"`
+	Version    string            `json:"Version"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// policies returns ssm parameter policies as json, or empty when there are none
+func (o ConfigOptions) policies() (string, error) {
+	var policies []ssmPolicy
+
+	if o.Expiration != "" {
+		policies = append(policies, ssmPolicy{
+			Type:       "Expiration",
+			Version:    "1.0",
+			Attributes: map[string]string{"Timestamp": o.Expiration},
+		})
+	}
+
+	if o.ExpirationNotification > 0 {
+		policies = append(policies, ssmPolicy{
+			Type:    "ExpirationNotification",
+			Version: "1.0",
+			Attributes: map[string]string{
+				"Before": fmt.Sprint(o.ExpirationNotification),
+				"Unit":   "Days",
+			},
+		})
+	}
+
+	if o.NoChangeNotification > 0 {
+		policies = append(policies, ssmPolicy{
+			Type:    "NoChangeNotification",
+			Version: "1.0",
+			Attributes: map[string]string{
+				"After": fmt.Sprint(o.NoChangeNotification),
+				"Unit":  "Days",
+			},
+		})
+	}
+
+	if len(policies) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(policies)
+	return string(b), err
+}
+
+func oneOf(name string, value string, allowed []string) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("'%s' must be one of %v", name, allowed)
+}
@@ -1,61 +1,229 @@
 package store
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
-	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adikari/safebox/v2/aws"
+	"github.com/adikari/safebox/v2/util"
+	a "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
 	"github.com/pkg/errors"
 )
 
 var _ Store = &SecretsManagerStore{}
 
+// SecretsManagerAPI is the part of the secrets manager client used by
+// SecretsManagerStore
+type SecretsManagerAPI interface {
+	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	UpdateSecret(ctx context.Context, params *secretsmanager.UpdateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretOutput, error)
+	TagResource(ctx context.Context, params *secretsmanager.TagResourceInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error)
+	RestoreSecret(ctx context.Context, params *secretsmanager.RestoreSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RestoreSecretOutput, error)
+	RotateSecret(ctx context.Context, params *secretsmanager.RotateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RotateSecretOutput, error)
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
+	ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
+	DeleteSecret(ctx context.Context, params *secretsmanager.DeleteSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error)
+	ListSecretVersionIds(ctx context.Context, params *secretsmanager.ListSecretVersionIdsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretVersionIdsOutput, error)
+}
+
 type SecretsManagerStore struct {
-	svc secretsmanageriface.SecretsManagerAPI
+	svc SecretsManagerAPI
 }
 
-func NewSecretsManagerStore(session *session.Session) (*SecretsManagerStore, error) {
-	secretsmanagerService := secretsmanager.New(session)
+func NewSecretsManagerStore(session aws.Session) (*SecretsManagerStore, error) {
+	secretsmanagerService := secretsmanager.NewFromConfig(session.Config, func(o *secretsmanager.Options) {
+		o.BaseEndpoint = session.Endpoint("secretsmanager")
+	})
 
 	return &SecretsManagerStore{
 		svc: secretsmanagerService,
 	}, nil
 }
 
-func (s *SecretsManagerStore) Create(input ConfigInput) error {
+func (s *SecretsManagerStore) Create(ctx context.Context, input ConfigInput) error {
 	param := &secretsmanager.CreateSecretInput{
-		Name:         aws.String(input.Name),
-		SecretString: aws.String(input.Value),
+		Name: a.String(input.Name),
+	}
+
+	if err := setSecretValue(input, &param.SecretString, &param.SecretBinary); err != nil {
+		return err
+	}
+
+	if input.Description != "" {
+		param.Description = a.String(input.Description)
+	}
+
+	if input.Options.KmsKeyId != "" {
+		param.KmsKeyId = a.String(input.Options.KmsKeyId)
 	}
 
-	if _, err := s.svc.CreateSecret(param); err != nil {
+	for key, value := range input.Options.Tags {
+		param.Tags = append(param.Tags, types.Tag{Key: a.String(key), Value: a.String(value)})
+	}
+
+	if _, err := s.svc.CreateSecret(ctx, param); err != nil {
 		return errors.Wrap(err, input.Name)
 	}
 
-	return nil
+	return s.rotate(ctx, input, nil)
 }
 
-func (s *SecretsManagerStore) Update(input ConfigInput) error {
+func (s *SecretsManagerStore) Update(ctx context.Context, input ConfigInput) error {
 	param := &secretsmanager.UpdateSecretInput{
-		SecretId:     aws.String(input.Name),
-		SecretString: aws.String(input.Value),
+		SecretId: a.String(input.Name),
+	}
+
+	if err := setSecretValue(input, &param.SecretString, &param.SecretBinary); err != nil {
+		return err
+	}
+
+	if input.Description != "" {
+		param.Description = a.String(input.Description)
+	}
+
+	if input.Options.KmsKeyId != "" {
+		param.KmsKeyId = a.String(input.Options.KmsKeyId)
+	}
+
+	if _, err := s.svc.UpdateSecret(ctx, param); err != nil {
+		return errors.Wrap(err, input.Name)
+	}
+
+	if err := s.PutTags(ctx, input.Name, input.Options.Tags); err != nil {
+		return err
+	}
+
+	if input.Options.RotationLambdaArn == "" {
+		return nil
+	}
+
+	current, err := s.svc.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: a.String(input.Name),
+	})
+
+	if err != nil {
+		return errors.Wrap(err, input.Name)
+	}
+
+	return s.rotate(ctx, input, current)
+}
+
+func (s *SecretsManagerStore) PutTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var t []types.Tag
+	for key, value := range tags {
+		t = append(t, types.Tag{Key: a.String(key), Value: a.String(value)})
+	}
+
+	_, err := s.svc.TagResource(ctx, &secretsmanager.TagResourceInput{
+		SecretId: a.String(name),
+		Tags:     t,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, name)
 	}
 
-	if _, err := s.svc.UpdateSecret(param); err != nil {
+	return nil
+}
+
+// Restore cancels deletion of a secret scheduled for deletion
+func (s *SecretsManagerStore) Restore(ctx context.Context, input ConfigInput) error {
+	_, err := s.svc.RestoreSecret(ctx, &secretsmanager.RestoreSecretInput{
+		SecretId: a.String(input.Name),
+	})
+
+	if err != nil {
 		return errors.Wrap(err, input.Name)
 	}
 
 	return nil
 }
 
-func (s *SecretsManagerStore) Put(input ConfigInput) error {
-	found, _ := s.Get(input)
+// rotate configures native rotation without rotating immediately. Rotation
+// is left alone when current, the description of an existing secret, already
+// rotates with the same lambda and schedule, as configuring it again runs the
+// rotation lambda.
+func (s *SecretsManagerStore) rotate(ctx context.Context, input ConfigInput, current *secretsmanager.DescribeSecretOutput) error {
+	if input.Options.RotationLambdaArn == "" {
+		return nil
+	}
+
+	if current != nil && a.ToBool(current.RotationEnabled) && a.ToString(current.RotationLambdaARN) == input.Options.RotationLambdaArn {
+		schedule := ""
+		if current.RotationRules != nil {
+			schedule = a.ToString(current.RotationRules.ScheduleExpression)
+		}
+
+		if input.Options.RotationSchedule == "" || schedule == input.Options.RotationSchedule {
+			return nil
+		}
+	}
+
+	param := &secretsmanager.RotateSecretInput{
+		SecretId:          a.String(input.Name),
+		RotationLambdaARN: a.String(input.Options.RotationLambdaArn),
+		RotateImmediately: a.Bool(false),
+	}
+
+	if input.Options.RotationSchedule != "" {
+		param.RotationRules = &types.RotationRulesType{
+			ScheduleExpression: a.String(input.Options.RotationSchedule),
+		}
+	}
+
+	if _, err := s.svc.RotateSecret(ctx, param); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to configure rotation of %s", input.Name))
+	}
+
+	return nil
+}
+
+func (s *SecretsManagerStore) Put(ctx context.Context, input ConfigInput) error {
+	found, err := s.Get(ctx, input)
+
+	// secrets scheduled for deletion can not be read
+	if isErrorCode(err, errInvalidRequest) {
+		deleted, derr := s.isDeleted(ctx, input)
+
+		if derr != nil {
+			return derr
+		}
+
+		if !deleted {
+			return errors.Wrap(err, input.Name)
+		}
+
+		if err := s.Restore(ctx, input); err != nil {
+			return err
+		}
+
+		found, err = &Config{Name: a.String(input.Name)}, nil
+	}
+
+	if isErrorCode(err, errResourceNotFound) {
+		found, err = nil, nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, input.Name)
+	}
 
-	var err error
 	if found != nil {
-		err = s.Update(input)
+		err = s.Update(ctx, input)
 	} else {
-		err = s.Create(input)
+		err = s.Create(ctx, input)
 	}
 
 	if err != nil {
@@ -65,9 +233,39 @@ func (s *SecretsManagerStore) Put(input ConfigInput) error {
 	return nil
 }
 
-func (s *SecretsManagerStore) PutMany(inputs []ConfigInput) error {
+func (s *SecretsManagerStore) isDeleted(ctx context.Context, input ConfigInput) (bool, error) {
+	resp, err := s.svc.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: a.String(input.Name),
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return resp.DeletedDate != nil, nil
+}
+
+// setSecretValue sets value as string, or as binary for base64 encoded
+// binary secrets
+func setSecretValue(input ConfigInput, str **string, binary *[]byte) error {
+	if !input.Options.Binary {
+		*str = a.String(input.Value)
+		return nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(input.Value)
+
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%s is binary and must be base64 encoded", input.Name))
+	}
+
+	*binary = b
+	return nil
+}
+
+func (s *SecretsManagerStore) PutMany(ctx context.Context, inputs []ConfigInput) error {
 	for _, config := range inputs {
-		if err := s.Put(config); err != nil {
+		if err := s.Put(ctx, config); err != nil {
 			return err
 		}
 	}
@@ -75,99 +273,307 @@ func (s *SecretsManagerStore) PutMany(inputs []ConfigInput) error {
 	return nil
 }
 
-func (s *SecretsManagerStore) Get(input ConfigInput) (*Config, error) {
+func (s *SecretsManagerStore) Get(ctx context.Context, input ConfigInput) (*Config, error) {
 	param := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(input.Name),
+		SecretId: a.String(input.Name),
 	}
 
-	result, err := s.svc.GetSecretValue(param)
+	result, err := s.svc.GetSecretValue(ctx, param)
 
 	if err != nil {
 		return nil, err
 	}
 
+	value := result.SecretString
+	if value == nil && result.SecretBinary != nil {
+		value = a.String(base64.StdEncoding.EncodeToString(result.SecretBinary))
+	}
+
 	return &Config{
 		Name:     result.Name,
-		Value:    result.SecretString,
-		Version:  *result.VersionId,
+		Value:    value,
+		Version:  a.ToString(result.VersionId),
 		Type:     "SecureString",
 		DataType: "SecureString",
-		Modified: *result.CreatedDate,
+		Modified: a.ToTime(result.CreatedDate),
 	}, nil
 }
 
-func (s *SecretsManagerStore) GetMany(inputs []ConfigInput) ([]Config, error) {
+// GetMany reads secrets in batches. Secrets that do not exist are skipped.
+func (s *SecretsManagerStore) GetMany(ctx context.Context, inputs []ConfigInput) ([]Config, error) {
 	if len(inputs) <= 0 {
 		return []Config{}, nil
 	}
 
 	result := []Config{}
 
+	for _, chunk := range util.ChunkSlice(inputs, 20) {
+		configs, err := s.batchGet(ctx, chunk)
+
+		if isErrorCode(err, "AccessDeniedException") {
+			// batch reads need their own permission, fall back to reading one by one
+			configs, err = s.getEach(ctx, chunk)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, configs...)
+	}
+
+	return result, nil
+}
+
+func (s *SecretsManagerStore) batchGet(ctx context.Context, inputs []ConfigInput) ([]Config, error) {
+	result := []Config{}
+
+	param := &secretsmanager.BatchGetSecretValueInput{
+		SecretIdList: getNames(inputs),
+	}
+
+	for {
+		resp, err := s.svc.BatchGetSecretValue(ctx, param)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range resp.Errors {
+			code := a.ToString(e.ErrorCode)
+
+			// missing secrets and secrets scheduled for deletion
+			if code == errResourceNotFound || code == errInvalidRequest {
+				continue
+			}
+
+			return nil, errors.Errorf("%s: %s", a.ToString(e.SecretId), a.ToString(e.Message))
+		}
+
+		for _, v := range resp.SecretValues {
+			result = append(result, secretValueToConfig(v))
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+
+		param.NextToken = resp.NextToken
+	}
+
+	return result, nil
+}
+
+func (s *SecretsManagerStore) getEach(ctx context.Context, inputs []ConfigInput) ([]Config, error) {
+	result := []Config{}
+
 	for _, input := range inputs {
-		res, _ := s.Get(input)
-		if res != nil {
-			result = append(result, *res)
+		res, err := s.Get(ctx, input)
+
+		if isErrorCode(err, errResourceNotFound) || isErrorCode(err, errInvalidRequest) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
 		}
+
+		result = append(result, *res)
 	}
 
 	return result, nil
 }
 
-func (s *SecretsManagerStore) GetByPath(path string) ([]Config, error) {
+// GetByPath returns all secrets with names starting with path, with metadata
+func (s *SecretsManagerStore) GetByPath(ctx context.Context, path string) ([]Config, error) {
 	var result []Config
 
+	// name filter matches loosely, results are filtered by exact prefix below
 	input := &secretsmanager.ListSecretsInput{
-		Filters: []*secretsmanager.Filter{
+		Filters: []types.Filter{
 			{
-				Key:    aws.String("name"),
-				Values: []*string{aws.String(path)},
+				Key:    types.FilterNameStringTypeName,
+				Values: []string{path},
 			},
 		},
 	}
 
-	var recursiveGet func()
-	recursiveGet = func() {
-		resp, err := s.svc.ListSecrets(input)
+	for {
+		resp, err := s.svc.ListSecrets(ctx, input)
 
 		if err != nil {
-			return
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to list secrets by path %s", path))
 		}
 
 		for _, secret := range resp.SecretList {
-			result = append(result, Config{Name: secret.Name})
+			if strings.HasPrefix(a.ToString(secret.Name), path) {
+				result = append(result, secretEntryToConfig(secret))
+			}
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+
+		input.NextToken = resp.NextToken
+	}
+
+	var inputs []ConfigInput
+	for _, c := range result {
+		inputs = append(inputs, ConfigInput{Name: *c.Name})
+	}
+
+	values, err := s.GetMany(ctx, inputs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range result {
+		for _, v := range values {
+			if *v.Name == *c.Name {
+				result[i].Value = v.Value
+				result[i].Version = v.Version
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// GetHistory returns versions of a secret still kept by secrets manager
+func (s *SecretsManagerStore) GetHistory(ctx context.Context, name string) ([]Config, error) {
+	var result []Config
+
+	paginator := secretsmanager.NewListSecretVersionIdsPaginator(s.svc, &secretsmanager.ListSecretVersionIdsInput{
+		SecretId: a.String(name),
+	})
+
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return nil, errors.Wrap(err, name)
 		}
 
-		if resp.NextToken != nil {
-			input.NextToken = resp.NextToken
-			recursiveGet()
+		for _, v := range resp.Versions {
+			value, err := s.svc.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+				SecretId:  a.String(name),
+				VersionId: v.VersionId,
+			})
+
+			if err != nil {
+				return nil, errors.Wrap(err, name)
+			}
+
+			c := secretValueToConfig(types.SecretValueEntry{
+				Name:          a.String(name),
+				SecretString:  value.SecretString,
+				SecretBinary:  value.SecretBinary,
+				VersionId:     v.VersionId,
+				VersionStages: v.VersionStages,
+				CreatedDate:   v.CreatedDate,
+			})
+
+			result = append(result, c)
 		}
 	}
 
-	recursiveGet()
+	sort.Slice(result, func(i, j int) bool { return result[i].Modified.After(result[j].Modified) })
 
 	return result, nil
 }
 
-func (s *SecretsManagerStore) Delete(input ConfigInput) error {
+func secretEntryToConfig(secret types.SecretListEntry) Config {
+	c := Config{
+		Name:        secret.Name,
+		Value:       a.String(""),
+		Type:        "SecureString",
+		DataType:    "SecureString",
+		Description: a.ToString(secret.Description),
+		Tags:        map[string]string{},
+	}
+
+	if secret.LastChangedDate != nil {
+		c.Modified = *secret.LastChangedDate
+	}
+
+	if secret.CreatedDate != nil {
+		c.Created = *secret.CreatedDate
+	}
+
+	for _, t := range secret.Tags {
+		c.Tags[a.ToString(t.Key)] = a.ToString(t.Value)
+	}
+
+	for version, stages := range secret.SecretVersionsToStages {
+		for _, stage := range stages {
+			if stage == "AWSCURRENT" {
+				c.Version = version
+			}
+		}
+	}
+
+	return c
+}
+
+func secretValueToConfig(v types.SecretValueEntry) Config {
+	value := v.SecretString
+	if value == nil && v.SecretBinary != nil {
+		value = a.String(base64.StdEncoding.EncodeToString(v.SecretBinary))
+	}
+
+	c := Config{
+		Name:          v.Name,
+		Value:         value,
+		Version:       a.ToString(v.VersionId),
+		Type:          "SecureString",
+		DataType:      "SecureString",
+		VersionStages: v.VersionStages,
+	}
+
+	if v.CreatedDate != nil {
+		c.Modified = *v.CreatedDate
+	}
+
+	return c
+}
+
+const (
+	errResourceNotFound = "ResourceNotFoundException"
+	errInvalidRequest   = "InvalidRequestException"
+)
+
+func isErrorCode(err error, code string) bool {
+	var aerr smithy.APIError
+	return errors.As(err, &aerr) && aerr.ErrorCode() == code
+}
+
+func (s *SecretsManagerStore) Delete(ctx context.Context, input ConfigInput) error {
 	param := &secretsmanager.DeleteSecretInput{
-		ForceDeleteWithoutRecovery: aws.Bool(true),
-		SecretId:                   aws.String(input.Name),
+		SecretId: a.String(input.Name),
+	}
+
+	if input.Options.RecoveryWindow > 0 {
+		param.RecoveryWindowInDays = a.Int64(int64(input.Options.RecoveryWindow))
+	} else {
+		param.ForceDeleteWithoutRecovery = a.Bool(true)
 	}
 
-	if _, err := s.svc.DeleteSecret(param); err != nil {
+	if _, err := s.svc.DeleteSecret(ctx, param); err != nil {
 		return errors.Wrap(err, input.Name)
 	}
 
 	return nil
 }
 
-func (s *SecretsManagerStore) DeleteMany(inputs []ConfigInput) error {
+func (s *SecretsManagerStore) DeleteMany(ctx context.Context, inputs []ConfigInput) error {
 	if len(inputs) <= 0 {
 		return nil
 	}
 
 	for _, input := range inputs {
-		if err := s.Delete(input); err != nil {
+		if err := s.Delete(ctx, input); err != nil {
 			return err
 		}
 	}
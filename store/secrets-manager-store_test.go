@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	a "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeSecretsManager records calls. Methods not used by a test panic.
+type fakeSecretsManager struct {
+	SecretsManagerAPI
+	getErr  error
+	deleted bool
+	// rotation is the current rotation lambda and schedule, when enabled
+	rotation []string
+	// bare values have no version and creation date
+	bare bool
+	// secrets are listed one a page
+	secrets  []types.SecretListEntry
+	listErr  error
+	batchErr error
+	calls    []string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	f.calls = append(f.calls, "GetSecretValue")
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	if f.bare {
+		return &secretsmanager.GetSecretValueOutput{Name: params.SecretId, SecretString: a.String("old")}, nil
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		Name:         params.SecretId,
+		SecretString: a.String("old"),
+		VersionId:    a.String("v1"),
+		CreatedDate:  a.Time(time.Now()),
+	}, nil
+}
+
+func (f *fakeSecretsManager) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	f.calls = append(f.calls, "DescribeSecret")
+	out := &secretsmanager.DescribeSecretOutput{Name: params.SecretId}
+	if f.deleted {
+		out.DeletedDate = a.Time(time.Now())
+	}
+	if f.rotation != nil {
+		out.RotationEnabled = a.Bool(true)
+		out.RotationLambdaARN = a.String(f.rotation[0])
+		out.RotationRules = &types.RotationRulesType{ScheduleExpression: a.String(f.rotation[1])}
+	}
+	return out, nil
+}
+
+func (f *fakeSecretsManager) RotateSecret(ctx context.Context, params *secretsmanager.RotateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RotateSecretOutput, error) {
+	f.calls = append(f.calls, "RotateSecret")
+	return &secretsmanager.RotateSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) RestoreSecret(ctx context.Context, params *secretsmanager.RestoreSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RestoreSecretOutput, error) {
+	f.calls = append(f.calls, "RestoreSecret")
+	return &secretsmanager.RestoreSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	f.calls = append(f.calls, "CreateSecret")
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) UpdateSecret(ctx context.Context, params *secretsmanager.UpdateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretOutput, error) {
+	f.calls = append(f.calls, "UpdateSecret")
+	return &secretsmanager.UpdateSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
+	f.calls = append(f.calls, "ListSecrets")
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+
+	i, _ := strconv.Atoi(a.ToString(params.NextToken))
+	out := &secretsmanager.ListSecretsOutput{}
+	if i < len(f.secrets) {
+		out.SecretList = f.secrets[i : i+1]
+	}
+	if i+1 < len(f.secrets) {
+		out.NextToken = a.String(strconv.Itoa(i + 1))
+	}
+	return out, nil
+}
+
+func (f *fakeSecretsManager) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	f.calls = append(f.calls, "BatchGetSecretValue")
+	if f.batchErr != nil {
+		return nil, f.batchErr
+	}
+
+	out := &secretsmanager.BatchGetSecretValueOutput{}
+	for _, name := range params.SecretIdList {
+		out.SecretValues = append(out.SecretValues, types.SecretValueEntry{
+			Name:         a.String(name),
+			SecretString: a.String("value of " + name),
+			VersionId:    a.String("v2"),
+		})
+	}
+	return out, nil
+}
+
+func apiError(code string) error {
+	return &smithy.GenericAPIError{Code: code, Message: code}
+}
+
+func TestSecretsManagerPut(t *testing.T) {
+	tests := []struct {
+		name      string
+		getErr    error
+		deleted   bool
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:      "creates missing secret",
+			getErr:    apiError(errResourceNotFound),
+			wantCalls: []string{"GetSecretValue", "CreateSecret"},
+		},
+		{
+			name:      "updates existing secret",
+			wantCalls: []string{"GetSecretValue", "UpdateSecret"},
+		},
+		{
+			name:      "restores secret scheduled for deletion",
+			getErr:    apiError(errInvalidRequest),
+			deleted:   true,
+			wantCalls: []string{"GetSecretValue", "DescribeSecret", "RestoreSecret", "UpdateSecret"},
+		},
+		{
+			name:      "fails on invalid request",
+			getErr:    apiError(errInvalidRequest),
+			wantCalls: []string{"GetSecretValue", "DescribeSecret"},
+			wantErr:   true,
+		},
+		{
+			name:      "fails when secret can not be read",
+			getErr:    apiError("AccessDeniedException"),
+			wantCalls: []string{"GetSecretValue"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSecretsManager{getErr: tt.getErr, deleted: tt.deleted}
+			s := &SecretsManagerStore{svc: fake}
+
+			err := s.Put(context.Background(), ConfigInput{Name: "/dev/api/KEY", Value: "new"})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Put() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(fake.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", fake.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSecretsManagerRotation(t *testing.T) {
+	const lambda = "arn:aws:lambda:us-east-1:1:function:rotate"
+
+	tests := []struct {
+		name      string
+		getErr    error
+		rotation  []string
+		schedule  string
+		wantCalls []string
+	}{
+		{
+			name:      "configured for new secret",
+			getErr:    apiError(errResourceNotFound),
+			schedule:  "rate(30 days)",
+			wantCalls: []string{"GetSecretValue", "CreateSecret", "RotateSecret"},
+		},
+		{
+			name:      "configured when not rotating",
+			schedule:  "rate(30 days)",
+			wantCalls: []string{"GetSecretValue", "UpdateSecret", "DescribeSecret", "RotateSecret"},
+		},
+		{
+			name:      "unchanged",
+			rotation:  []string{lambda, "rate(30 days)"},
+			schedule:  "rate(30 days)",
+			wantCalls: []string{"GetSecretValue", "UpdateSecret", "DescribeSecret"},
+		},
+		{
+			name:      "unchanged without schedule",
+			rotation:  []string{lambda, "rate(30 days)"},
+			wantCalls: []string{"GetSecretValue", "UpdateSecret", "DescribeSecret"},
+		},
+		{
+			name:      "schedule changed",
+			rotation:  []string{lambda, "rate(30 days)"},
+			schedule:  "rate(7 days)",
+			wantCalls: []string{"GetSecretValue", "UpdateSecret", "DescribeSecret", "RotateSecret"},
+		},
+		{
+			name:      "lambda changed",
+			rotation:  []string{"arn:aws:lambda:us-east-1:1:function:old", "rate(30 days)"},
+			schedule:  "rate(30 days)",
+			wantCalls: []string{"GetSecretValue", "UpdateSecret", "DescribeSecret", "RotateSecret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSecretsManager{getErr: tt.getErr, rotation: tt.rotation}
+			s := &SecretsManagerStore{svc: fake}
+
+			input := ConfigInput{
+				Name:    "/dev/api/KEY",
+				Value:   "new",
+				Options: ConfigOptions{RotationLambdaArn: lambda, RotationSchedule: tt.schedule},
+			}
+
+			if err := s.Put(context.Background(), input); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(fake.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", fake.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSecretsManagerGetWithoutMetadata(t *testing.T) {
+	s := &SecretsManagerStore{svc: &fakeSecretsManager{bare: true}}
+
+	got, err := s.Get(context.Background(), ConfigInput{Name: "/dev/api/KEY"})
+
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if *got.Value != "old" || got.Version != "" || !got.Modified.IsZero() {
+		t.Errorf("Get() = %+v", got)
+	}
+}
+
+func TestSecretsManagerGetByPath(t *testing.T) {
+	changed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	fake := &fakeSecretsManager{secrets: []types.SecretListEntry{
+		{
+			Name:                   a.String("/dev/api/A"),
+			Description:            a.String("about A"),
+			LastChangedDate:        a.Time(changed),
+			Tags:                   []types.Tag{{Key: a.String("service"), Value: a.String("api")}},
+			SecretVersionsToStages: map[string][]string{"v1": {"AWSPREVIOUS"}, "v2": {"AWSCURRENT"}},
+		},
+		// the name filter also matches other paths
+		{Name: a.String("/prod/dev/api/B")},
+		{Name: a.String("/dev/api-old/C")},
+		{Name: a.String("/dev/api/D")},
+	}}
+	s := &SecretsManagerStore{svc: fake}
+
+	got, err := s.GetByPath(context.Background(), "/dev/api/")
+
+	if err != nil {
+		t.Fatalf("GetByPath() error = %v", err)
+	}
+
+	names := []string{}
+	for _, c := range got {
+		names = append(names, *c.Name)
+	}
+
+	if want := []string{"/dev/api/A", "/dev/api/D"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("GetByPath() = %v, want %v", names, want)
+	}
+
+	first := got[0]
+	if *first.Value != "value of /dev/api/A" || first.Version != "v2" || first.Description != "about A" || !first.Modified.Equal(changed) {
+		t.Errorf("GetByPath() = %+v, want value, version and metadata", first)
+	}
+
+	if !reflect.DeepEqual(first.Tags, map[string]string{"service": "api"}) {
+		t.Errorf("tags = %v, want service tag", first.Tags)
+	}
+
+	if want := []string{"ListSecrets", "ListSecrets", "ListSecrets", "ListSecrets", "BatchGetSecretValue"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+}
+
+func TestSecretsManagerGetByPathErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		fake      *fakeSecretsManager
+		wantErr   bool
+		wantValue string
+	}{
+		{
+			name:    "list fails",
+			fake:    &fakeSecretsManager{listErr: apiError("AccessDeniedException")},
+			wantErr: true,
+		},
+		{
+			name:    "batch read fails",
+			fake:    &fakeSecretsManager{batchErr: apiError("ThrottlingException")},
+			wantErr: true,
+		},
+		{
+			name:      "batch read is not allowed",
+			fake:      &fakeSecretsManager{batchErr: apiError("AccessDeniedException")},
+			wantValue: "old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fake.secrets = []types.SecretListEntry{{Name: a.String("/dev/api/A")}}
+			s := &SecretsManagerStore{svc: tt.fake}
+
+			got, err := s.GetByPath(context.Background(), "/dev/api/")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && (len(got) != 1 || *got[0].Value != tt.wantValue) {
+				t.Errorf("GetByPath() = %+v, want value %s", got, tt.wantValue)
+			}
+		})
+	}
+}
@@ -1,30 +1,51 @@
 package store
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/adikari/safebox/v2/aws"
 	"github.com/adikari/safebox/v2/util"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ssm"
-	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	a "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pkg/errors"
 )
 
 var _ Store = &SSMStore{}
 
+// SSMAPI is the part of the ssm client used by SSMStore
+type SSMAPI interface {
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	DeleteParameter(ctx context.Context, params *ssm.DeleteParameterInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error)
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+	AddTagsToResource(ctx context.Context, params *ssm.AddTagsToResourceInput, optFns ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error)
+	ListTagsForResource(ctx context.Context, params *ssm.ListTagsForResourceInput, optFns ...func(*ssm.Options)) (*ssm.ListTagsForResourceOutput, error)
+	GetParameterHistory(ctx context.Context, params *ssm.GetParameterHistoryInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterHistoryOutput, error)
+	DescribeParameters(ctx context.Context, params *ssm.DescribeParametersInput, optFns ...func(*ssm.Options)) (*ssm.DescribeParametersOutput, error)
+}
+
 type SSMStore struct {
-	svc ssmiface.SSMAPI
+	svc SSMAPI
 }
 
-func NewSSMStore(session *session.Session) (*SSMStore, error) {
-	svc := ssm.New(session)
+func NewSSMStore(session aws.Session) (*SSMStore, error) {
+	svc := ssm.NewFromConfig(session.Config, func(o *ssm.Options) {
+		o.BaseEndpoint = session.Endpoint("ssm")
+	})
 
 	return &SSMStore{svc: svc}, nil
 }
 
-func (s *SSMStore) PutMany(input []ConfigInput) error {
+// NewSSMStoreWithClient returns a store that uses svc, such as a fake in tests
+func NewSSMStoreWithClient(svc SSMAPI) *SSMStore {
+	return &SSMStore{svc: svc}
+}
+
+func (s *SSMStore) PutMany(ctx context.Context, input []ConfigInput) error {
 	for _, config := range input {
-		if err := s.Put(config); err != nil {
+		if err := s.Put(ctx, config); err != nil {
 			return err
 		}
 	}
@@ -32,53 +53,136 @@ func (s *SSMStore) PutMany(input []ConfigInput) error {
 	return nil
 }
 
-func (s *SSMStore) Put(input ConfigInput) error {
-	configType := "String"
+func (s *SSMStore) Put(ctx context.Context, input ConfigInput) error {
+	configType := types.ParameterTypeString
 
 	if input.Secret == true {
-		configType = "SecureString"
+		configType = types.ParameterTypeSecureString
+	} else if input.Options.Type != "" {
+		configType = types.ParameterType(input.Options.Type)
 	}
 
 	putParameterInput := &ssm.PutParameterInput{
-		Name:        aws.String(input.Name),
-		Type:        aws.String(configType),
-		Value:       aws.String(input.Value),
-		Description: aws.String(input.Description),
-		Overwrite:   aws.Bool(true),
+		Name:        a.String(input.Name),
+		Type:        configType,
+		Value:       a.String(input.Value),
+		Description: a.String(input.Description),
+		Overwrite:   a.Bool(true),
+	}
+
+	opts := input.Options
+
+	if opts.KmsKeyId != "" && input.Secret {
+		putParameterInput.KeyId = a.String(opts.KmsKeyId)
+	}
+
+	if opts.AllowedPattern != "" {
+		putParameterInput.AllowedPattern = a.String(opts.AllowedPattern)
+	}
+
+	if opts.DataType != "" {
+		putParameterInput.DataType = a.String(opts.DataType)
 	}
 
-	_, err := s.svc.PutParameter(putParameterInput)
+	policies, err := opts.policies()
 
 	if err != nil {
 		return err
 	}
 
+	tier := types.ParameterTier(opts.Tier)
+
+	// policies and values larger than 4KB require advanced tier
+	if tier == "" && (policies != "" || len(input.Value) > 4096) {
+		tier = types.ParameterTierAdvanced
+	}
+
+	putParameterInput.Tier = tier
+
+	if policies != "" {
+		putParameterInput.Policies = a.String(policies)
+	}
+
+	if _, err := s.svc.PutParameter(ctx, putParameterInput); err != nil {
+		return errors.Wrap(err, input.Name)
+	}
+
+	// tags can not be set on PutParameter when overwriting
+	return s.putChangedTags(ctx, input.Name, opts.Tags)
+}
+
+// putChangedTags adds tags missing from a parameter or with another value.
+// Tags are added anyway when current tags can not be read.
+func (s *SSMStore) putChangedTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	current, err := s.GetTags(ctx, name)
+
+	if err == nil {
+		changed := false
+		for k, v := range tags {
+			if value, ok := current[k]; !ok || value != v {
+				changed = true
+				break
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+	}
+
+	return s.PutTags(ctx, name, tags)
+}
+
+func (s *SSMStore) PutTags(ctx context.Context, name string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var t []types.Tag
+	for key, value := range tags {
+		t = append(t, types.Tag{Key: a.String(key), Value: a.String(value)})
+	}
+
+	_, err := s.svc.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   a.String(name),
+		Tags:         t,
+	})
+
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+
 	return nil
 }
 
-func (s *SSMStore) Delete(config ConfigInput) error {
-	if _, err := s.Get(config); err != nil {
+func (s *SSMStore) Delete(ctx context.Context, config ConfigInput) error {
+	if _, err := s.Get(ctx, config); err != nil {
 		return err
 	}
 
 	deleteParameterInput := &ssm.DeleteParameterInput{
-		Name: aws.String(config.Name),
+		Name: a.String(config.Name),
 	}
 
-	if _, err := s.svc.DeleteParameter(deleteParameterInput); err != nil {
+	if _, err := s.svc.DeleteParameter(ctx, deleteParameterInput); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *SSMStore) DeleteMany(configs []ConfigInput) error {
+func (s *SSMStore) DeleteMany(ctx context.Context, configs []ConfigInput) error {
 	if len(configs) <= 0 {
 		return nil
 	}
 
 	for _, config := range configs {
-		if err := s.Delete(config); err != nil {
+		if err := s.Delete(ctx, config); err != nil {
 			return err
 		}
 	}
@@ -86,7 +190,7 @@ func (s *SSMStore) DeleteMany(configs []ConfigInput) error {
 	return nil
 }
 
-func (s *SSMStore) GetMany(configs []ConfigInput) ([]Config, error) {
+func (s *SSMStore) GetMany(ctx context.Context, configs []ConfigInput) ([]Config, error) {
 	if len(configs) <= 0 {
 		return []Config{}, nil
 	}
@@ -96,10 +200,10 @@ func (s *SSMStore) GetMany(configs []ConfigInput) ([]Config, error) {
 
 		getParametersInput := &ssm.GetParametersInput{
 			Names:          getNames(c),
-			WithDecryption: aws.Bool(true),
+			WithDecryption: a.Bool(true),
 		}
 
-		resp, err := s.svc.GetParameters(getParametersInput)
+		resp, err := s.svc.GetParameters(ctx, getParametersInput)
 
 		if err != nil {
 			return []Config{}, err
@@ -126,69 +230,154 @@ func (s *SSMStore) GetMany(configs []ConfigInput) ([]Config, error) {
 	return params, nil
 }
 
-func (s *SSMStore) Get(config ConfigInput) (*Config, error) {
-	configs, err := s.GetMany([]ConfigInput{config})
+func (s *SSMStore) Get(ctx context.Context, config ConfigInput) (*Config, error) {
+	configs, err := s.GetMany(ctx, []ConfigInput{config})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if len(configs) == 0 {
+		return nil, errors.Wrap(ConfigNotFoundError, config.Name)
+	}
+
 	return &configs[0], nil
 }
 
-func (s *SSMStore) GetByPath(path string) ([]Config, error) {
+func (s *SSMStore) GetByPath(ctx context.Context, path string) ([]Config, error) {
+	return s.getByPath(ctx, path, false)
+}
+
+// GetTree returns all parameters under path, including nested paths
+func (s *SSMStore) GetTree(ctx context.Context, path string) ([]Config, error) {
+	return s.getByPath(ctx, path, true)
+}
+
+func (s *SSMStore) getByPath(ctx context.Context, path string, recursive bool) ([]Config, error) {
 	var result []Config
 
-	input := &ssm.GetParametersByPathInput{
-		Path:           aws.String(path),
-		WithDecryption: aws.Bool(true),
-	}
+	paginator := ssm.NewGetParametersByPathPaginator(s.svc, &ssm.GetParametersByPathInput{
+		Path:           a.String(path),
+		Recursive:      a.Bool(recursive),
+		WithDecryption: a.Bool(true),
+	})
 
-	var recursiveGet func()
-	recursiveGet = func() {
-		resp, err := s.svc.GetParametersByPath(input)
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
 
 		if err != nil {
-			return
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to get parameters by path %s", path))
 		}
 
 		for _, param := range resp.Parameters {
 			result = append(result, parameterToConfig(param))
 		}
+	}
+
+	return result, nil
+}
+
+func (s *SSMStore) GetTags(ctx context.Context, name string) (map[string]string, error) {
+	resp, err := s.svc.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: types.ResourceTypeForTaggingParameter,
+		ResourceId:   a.String(name),
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+
+	tags := map[string]string{}
+	for _, t := range resp.TagList {
+		tags[a.ToString(t.Key)] = a.ToString(t.Value)
+	}
+
+	return tags, nil
+}
+
+// GetDescriptions returns descriptions of parameters directly under path by name
+func (s *SSMStore) GetDescriptions(ctx context.Context, path string) (map[string]string, error) {
+	result := map[string]string{}
+
+	paginator := ssm.NewDescribeParametersPaginator(s.svc, &ssm.DescribeParametersInput{
+		ParameterFilters: []types.ParameterStringFilter{
+			{
+				Key:    a.String("Path"),
+				Option: a.String("OneLevel"),
+				Values: []string{path},
+			},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
 
-		if resp.NextToken != nil {
-			input.NextToken = resp.NextToken
-			recursiveGet()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to describe parameters by path %s", path))
+		}
+
+		for _, p := range resp.Parameters {
+			if p.Description != nil {
+				result[a.ToString(p.Name)] = a.ToString(p.Description)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (s *SSMStore) GetHistory(ctx context.Context, name string) ([]Config, error) {
+	var result []Config
+
+	paginator := ssm.NewGetParameterHistoryPaginator(s.svc, &ssm.GetParameterHistoryInput{
+		Name:           a.String(name),
+		WithDecryption: a.Bool(true),
+	})
+
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+
+		for _, h := range resp.Parameters {
+			result = append(result, Config{
+				Name:        h.Name,
+				Value:       h.Value,
+				Modified:    a.ToTime(h.LastModifiedDate),
+				Version:     fmt.Sprint(h.Version),
+				Type:        string(h.Type),
+				DataType:    a.ToString(h.DataType),
+				Description: a.ToString(h.Description),
+			})
 		}
 	}
 
-	recursiveGet()
+	// history is returned oldest first
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
 
 	return result, nil
 }
 
-func parameterToConfig(param *ssm.Parameter) Config {
+func parameterToConfig(param types.Parameter) Config {
 	return Config{
 		Name:     param.Name,
 		Value:    param.Value,
-		Modified: *param.LastModifiedDate,
-		Version:  fmt.Sprint(*param.Version),
-		Type:     *param.Type,
-		DataType: *param.DataType,
+		Modified: a.ToTime(param.LastModifiedDate),
+		Version:  fmt.Sprint(param.Version),
+		Type:     string(param.Type),
+		DataType: a.ToString(param.DataType),
 	}
 }
 
-func getNames(configs []ConfigInput) []*string {
-	var keys []string
+func getNames(configs []ConfigInput) []string {
+	var names []string
 
 	for _, value := range configs {
-		keys = append(keys, value.Name)
-	}
-
-	var names []*string
-
-	for _, key := range keys {
-		names = append(names, aws.String(key))
+		names = append(names, value.Name)
 	}
 
 	return names
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/adikari/safebox/v2/store/storetest"
+	a "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pkg/errors"
+)
+
+func TestSSMStoreGetHistory(t *testing.T) {
+	fake := storetest.NewFakeSSM("/dev/api/A")
+	fake.History["/dev/api/A"] = []types.ParameterHistory{
+		{Name: a.String("/dev/api/A"), Value: a.String("1"), Version: 1},
+		{Name: a.String("/dev/api/A"), Value: a.String("2"), Version: 2},
+		{Name: a.String("/dev/api/A"), Value: a.String("3"), Version: 3},
+	}
+
+	s := &SSMStore{svc: fake}
+	got, err := s.GetHistory(context.Background(), "/dev/api/A")
+
+	if err != nil {
+		t.Fatalf("GetHistory() error = %v", err)
+	}
+
+	versions := []string{}
+	for _, v := range got {
+		versions = append(versions, v.Version)
+	}
+
+	if want := []string{"3", "2", "1"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("versions = %v, want newest first %v", versions, want)
+	}
+}
+
+func TestSSMStoreGetDescriptions(t *testing.T) {
+	s := &SSMStore{svc: storetest.NewFakeSSM("/dev/api/A", "/dev/api/B", "/dev/api/nested/C")}
+
+	got, err := s.GetDescriptions(context.Background(), "/dev/api/")
+
+	if err != nil {
+		t.Fatalf("GetDescriptions() error = %v", err)
+	}
+
+	want := map[string]string{"/dev/api/A": "about /dev/api/A", "/dev/api/B": "about /dev/api/B"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetDescriptions() = %v, want %v", got, want)
+	}
+}
+
+func names(configs []Config) []string {
+	result := []string{}
+	for _, c := range configs {
+		result = append(result, *c.Name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestSSMStoreGet(t *testing.T) {
+	s := &SSMStore{svc: storetest.NewFakeSSM("/dev/api/HOST")}
+
+	got, err := s.Get(context.Background(), ConfigInput{Name: "/dev/api/HOST"})
+
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if *got.Value != "value of /dev/api/HOST" {
+		t.Errorf("Get() = %s", *got.Value)
+	}
+
+	if _, err := s.Get(context.Background(), ConfigInput{Name: "/dev/api/MISSING"}); errors.Cause(err) != ConfigNotFoundError {
+		t.Errorf("Get() of missing param error = %v, want not found", err)
+	}
+}
+
+func TestSSMStoreGetManyInChunks(t *testing.T) {
+	var params []string
+	var inputs []ConfigInput
+	for i := 0; i < 25; i++ {
+		name := fmt.Sprintf("/dev/api/KEY_%02d", i)
+		params = append(params, name)
+		inputs = append(inputs, ConfigInput{Name: name})
+	}
+
+	fake := storetest.NewFakeSSM(params...)
+	s := &SSMStore{svc: fake}
+
+	got, err := s.GetMany(context.Background(), append(inputs, ConfigInput{Name: "/dev/api/MISSING"}))
+
+	if err != nil {
+		t.Fatalf("GetMany() error = %v", err)
+	}
+
+	if len(got) != 25 {
+		t.Errorf("GetMany() returned %d params, want 25", len(got))
+	}
+
+	// ssm accepts at most 10 names at once
+	if fake.Gets != 3 {
+		t.Errorf("GetParameters called %d times, want 3", fake.Gets)
+	}
+}
+
+func TestSSMStoreGetByPathPages(t *testing.T) {
+	s := &SSMStore{svc: storetest.NewFakeSSM("/dev/api/A", "/dev/api/B", "/dev/api/C", "/dev/api/D", "/dev/api/nested/E", "/dev/other/F")}
+
+	got, err := s.GetByPath(context.Background(), "/dev/api/")
+
+	if err != nil {
+		t.Fatalf("GetByPath() error = %v", err)
+	}
+
+	// the fake returns two params a page
+	if want := []string{"/dev/api/A", "/dev/api/B", "/dev/api/C", "/dev/api/D"}; !reflect.DeepEqual(names(got), want) {
+		t.Errorf("GetByPath() = %v, want %v", names(got), want)
+	}
+}
+
+func TestSSMStoreGetTree(t *testing.T) {
+	s := &SSMStore{svc: storetest.NewFakeSSM("/dev/api/A", "/dev/api/nested/B", "/dev/shared/C", "/prod/api/D")}
+
+	got, err := s.GetTree(context.Background(), "/dev/")
+
+	if err != nil {
+		t.Fatalf("GetTree() error = %v", err)
+	}
+
+	if want := []string{"/dev/api/A", "/dev/api/nested/B", "/dev/shared/C"}; !reflect.DeepEqual(names(got), want) {
+		t.Errorf("GetTree() = %v, want %v", names(got), want)
+	}
+}
+
+func TestSSMStoreDeleteMany(t *testing.T) {
+	fake := storetest.NewFakeSSM("/dev/api/A", "/dev/api/B")
+	s := &SSMStore{svc: fake}
+
+	if err := s.DeleteMany(context.Background(), []ConfigInput{{Name: "/dev/api/A"}}); err != nil {
+		t.Fatalf("DeleteMany() error = %v", err)
+	}
+
+	if _, ok := fake.Params["/dev/api/A"]; ok {
+		t.Error("/dev/api/A was not deleted")
+	}
+
+	err := s.DeleteMany(context.Background(), []ConfigInput{{Name: "/dev/api/MISSING"}})
+
+	if errors.Cause(err) != ConfigNotFoundError {
+		t.Errorf("DeleteMany() of missing param error = %v, want not found", err)
+	}
+
+	if _, ok := fake.Params["/dev/api/B"]; !ok {
+		t.Error("/dev/api/B was deleted")
+	}
+}
+
+func TestSSMStorePut(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ConfigInput
+		wantType types.ParameterType
+		wantTier types.ParameterTier
+		wantKey  bool
+		wantTags map[string]string
+	}{
+		{
+			name:     "config",
+			input:    ConfigInput{Name: "/dev/api/A", Value: "a"},
+			wantType: types.ParameterTypeString,
+		},
+		{
+			name:     "secret with kms key and tags",
+			input:    ConfigInput{Name: "/dev/api/S", Value: "s", Secret: true, Options: ConfigOptions{KmsKeyId: "alias/app", Tags: map[string]string{"team": "a"}}},
+			wantType: types.ParameterTypeSecureString,
+			wantKey:  true,
+			wantTags: map[string]string{"team": "a"},
+		},
+		{
+			name:     "kms key of config is ignored",
+			input:    ConfigInput{Name: "/dev/api/A", Value: "a", Options: ConfigOptions{KmsKeyId: "alias/app"}},
+			wantType: types.ParameterTypeString,
+		},
+		{
+			name:     "string list",
+			input:    ConfigInput{Name: "/dev/api/L", Value: "a,b", Options: ConfigOptions{Type: "StringList"}},
+			wantType: types.ParameterTypeStringList,
+		},
+		{
+			name:     "large value",
+			input:    ConfigInput{Name: "/dev/api/BIG", Value: strings.Repeat("x", 4097)},
+			wantType: types.ParameterTypeString,
+			wantTier: types.ParameterTierAdvanced,
+		},
+		{
+			name:     "policies",
+			input:    ConfigInput{Name: "/dev/api/P", Value: "p", Options: ConfigOptions{NoChangeNotification: 90}},
+			wantType: types.ParameterTypeString,
+			wantTier: types.ParameterTierAdvanced,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := storetest.NewFakeSSM()
+			s := &SSMStore{svc: fake}
+
+			if err := s.Put(context.Background(), tt.input); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			put := fake.Puts[0]
+
+			if put.Type != tt.wantType {
+				t.Errorf("type = %s, want %s", put.Type, tt.wantType)
+			}
+
+			if put.Tier != tt.wantTier {
+				t.Errorf("tier = %s, want %s", put.Tier, tt.wantTier)
+			}
+
+			if (put.KeyId != nil) != tt.wantKey {
+				t.Errorf("key id = %v, want set %v", a.ToString(put.KeyId), tt.wantKey)
+			}
+
+			if !reflect.DeepEqual(fake.Tags[tt.input.Name], tt.wantTags) {
+				t.Errorf("tags = %v, want %v", fake.Tags[tt.input.Name], tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestSSMStorePutTagsOnlyWhenChanged(t *testing.T) {
+	fake := storetest.NewFakeSSM()
+	s := &SSMStore{svc: fake}
+	input := ConfigInput{Name: "/dev/api/A", Value: "a", Options: ConfigOptions{Tags: map[string]string{"team": "a"}}}
+
+	steps := []struct {
+		tags       map[string]string
+		wantTagged int
+	}{
+		{tags: map[string]string{"team": "a"}, wantTagged: 1},
+		{tags: map[string]string{"team": "a"}, wantTagged: 1},
+		{tags: map[string]string{"team": "b"}, wantTagged: 2},
+		{tags: nil, wantTagged: 2},
+	}
+
+	for i, step := range steps {
+		input.Options.Tags = step.tags
+
+		if err := s.Put(context.Background(), input); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+
+		if fake.Tagged != step.wantTagged {
+			t.Errorf("step %d: AddTagsToResource called %d times, want %d", i, fake.Tagged, step.wantTagged)
+		}
+	}
+}
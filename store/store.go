@@ -1,31 +1,36 @@
 package store
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/adikari/safebox/v2/aws"
 	"github.com/adikari/safebox/v2/util"
-	a "github.com/aws/aws-sdk-go/aws"
+	"github.com/pkg/errors"
 )
 
 type Config struct {
-	Name     *string
-	Value    *string
-	Modified time.Time
-	Created  time.Time
-	Version  string
-	Type     string
-	DataType string
+	Name          *string
+	Value         *string
+	Modified      time.Time
+	Created       time.Time
+	Version       string
+	Type          string
+	DataType      string
+	Description   string            `json:",omitempty"`
+	Tags          map[string]string `json:",omitempty"`
+	VersionStages []string          `json:",omitempty"`
 }
 
 type ConfigInput struct {
 	Name        string
 	Value       string
 	Secret      bool
+	Shared      bool
 	Description string
+	Options     ConfigOptions
 }
 
 var (
@@ -33,25 +38,68 @@ var (
 )
 
 type Store interface {
-	PutMany(input []ConfigInput) error
-	Get(input ConfigInput) (*Config, error)
-	GetMany(inputs []ConfigInput) ([]Config, error)
-	GetByPath(path string) ([]Config, error)
-	DeleteMany(inputs []ConfigInput) error
+	PutMany(ctx context.Context, input []ConfigInput) error
+	Get(ctx context.Context, input ConfigInput) (*Config, error)
+	GetMany(ctx context.Context, inputs []ConfigInput) ([]Config, error)
+	GetByPath(ctx context.Context, path string) ([]Config, error)
+	DeleteMany(ctx context.Context, inputs []ConfigInput) error
+}
+
+// TagReader is implemented by stores that read tags of a parameter separately
+type TagReader interface {
+	GetTags(ctx context.Context, name string) (map[string]string, error)
+}
+
+// HistoryReader is implemented by stores that keep previous versions of a
+// parameter. Versions are returned newest first.
+type HistoryReader interface {
+	GetHistory(ctx context.Context, name string) ([]Config, error)
+}
+
+// TreeReader is implemented by stores whose GetByPath only returns direct
+// children of the path
+type TreeReader interface {
+	GetTree(ctx context.Context, path string) ([]Config, error)
+}
+
+// DescriptionReader is implemented by stores that do not return descriptions
+// of parameters listed by path
+type DescriptionReader interface {
+	GetDescriptions(ctx context.Context, path string) (map[string]string, error)
+}
+
+// TagWriter is implemented by stores that can add tags to a parameter
+type TagWriter interface {
+	PutTags(ctx context.Context, name string, tags map[string]string) error
 }
 
 type StoreConfig struct {
 	Provider string
 	Region   string
 	FilePath string
+	// Session selects the aws profile and role of aws providers
+	Session aws.SessionConfig
 }
 
-func GetStore(cfg StoreConfig) (Store, error) {
+func GetStore(ctx context.Context, cfg StoreConfig) (Store, error) {
+	if util.IsAwsProvider(cfg.Provider) {
+		sessionConfig := cfg.Session
+		sessionConfig.Region = cfg.Region
+
+		session, err := aws.NewSession(ctx, sessionConfig)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create aws session")
+		}
+
+		if cfg.Provider == util.SecretsManagerProvider {
+			return NewSecretsManagerStore(session)
+		}
+
+		return NewSSMStore(session)
+	}
+
 	switch cfg.Provider {
-	case util.SsmProvider:
-		return NewSSMStore(aws.NewSession(a.Config{Region: &cfg.Region}))
-	case util.SecretsManagerProvider:
-		return NewSecretsManagerStore(aws.NewSession(a.Config{Region: &cfg.Region}))
 	case util.GpgProvider:
 		return NewGpgStore(GpgStoreOptions{Path: cfg.FilePath})
 	default:
@@ -0,0 +1,122 @@
+// Package storetest provides fakes of the aws clients used by stores
+package storetest
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	a "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/pkg/errors"
+)
+
+// FakeSSM is an ssm client that keeps parameters in memory and pages results
+// of paths two at a time
+type FakeSSM struct {
+	Params  map[string]types.Parameter
+	History map[string][]types.ParameterHistory
+	Puts    []*ssm.PutParameterInput
+	Tags    map[string]map[string]string
+	Tagged  int
+	Gets    int
+}
+
+// NewFakeSSM returns a client with a parameter of each name
+func NewFakeSSM(params ...string) *FakeSSM {
+	f := &FakeSSM{Params: map[string]types.Parameter{}, History: map[string][]types.ParameterHistory{}, Tags: map[string]map[string]string{}}
+	for _, name := range params {
+		f.Params[name] = types.Parameter{Name: a.String(name), Value: a.String("value of " + name), Type: types.ParameterTypeString, Version: 1}
+	}
+	return f
+}
+
+func (f *FakeSSM) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	f.Puts = append(f.Puts, params)
+	f.Params[*params.Name] = types.Parameter{Name: params.Name, Value: params.Value, Type: params.Type}
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *FakeSSM) DeleteParameter(ctx context.Context, params *ssm.DeleteParameterInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error) {
+	delete(f.Params, *params.Name)
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+func (f *FakeSSM) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	f.Gets++
+	if len(params.Names) > 10 {
+		return nil, errors.New("ValidationException: at most 10 names")
+	}
+
+	out := &ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		if p, ok := f.Params[name]; ok {
+			out.Parameters = append(out.Parameters, p)
+		} else {
+			out.InvalidParameters = append(out.InvalidParameters, name)
+		}
+	}
+	return out, nil
+}
+
+func (f *FakeSSM) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	var names []string
+	for name := range f.Params {
+		rest := strings.TrimPrefix(name, *params.Path)
+		if strings.HasPrefix(name, *params.Path) && (a.ToBool(params.Recursive) || !strings.Contains(rest, "/")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, _ := strconv.Atoi(a.ToString(params.NextToken))
+	end := start + 2
+
+	out := &ssm.GetParametersByPathOutput{}
+	if end < len(names) {
+		out.NextToken = a.String(strconv.Itoa(end))
+	} else {
+		end = len(names)
+	}
+
+	for _, name := range names[start:end] {
+		out.Parameters = append(out.Parameters, f.Params[name])
+	}
+	return out, nil
+}
+
+func (f *FakeSSM) AddTagsToResource(ctx context.Context, params *ssm.AddTagsToResourceInput, optFns ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error) {
+	f.Tagged++
+	if f.Tags[*params.ResourceId] == nil {
+		f.Tags[*params.ResourceId] = map[string]string{}
+	}
+	for _, t := range params.Tags {
+		f.Tags[*params.ResourceId][*t.Key] = *t.Value
+	}
+	return &ssm.AddTagsToResourceOutput{}, nil
+}
+
+func (f *FakeSSM) ListTagsForResource(ctx context.Context, params *ssm.ListTagsForResourceInput, optFns ...func(*ssm.Options)) (*ssm.ListTagsForResourceOutput, error) {
+	out := &ssm.ListTagsForResourceOutput{}
+	for k, v := range f.Tags[*params.ResourceId] {
+		out.TagList = append(out.TagList, types.Tag{Key: a.String(k), Value: a.String(v)})
+	}
+	return out, nil
+}
+
+func (f *FakeSSM) GetParameterHistory(ctx context.Context, params *ssm.GetParameterHistoryInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterHistoryOutput, error) {
+	return &ssm.GetParameterHistoryOutput{Parameters: f.History[*params.Name]}, nil
+}
+
+func (f *FakeSSM) DescribeParameters(ctx context.Context, params *ssm.DescribeParametersInput, optFns ...func(*ssm.Options)) (*ssm.DescribeParametersOutput, error) {
+	out := &ssm.DescribeParametersOutput{}
+	path := params.ParameterFilters[0].Values[0]
+	for name := range f.Params {
+		if strings.HasPrefix(name, path) && !strings.Contains(strings.TrimPrefix(name, path), "/") {
+			out.Parameters = append(out.Parameters, types.ParameterMetadata{Name: a.String(name), Description: a.String("about " + name)})
+		}
+	}
+	return out, nil
+}